@@ -1,27 +1,48 @@
 package cpu
 
 import (
+	"fmt"
 	"slices"
 	"strconv"
 	"strings"
 )
 
+// jumpInstructions take an address operand that may be given as a label
+var jumpInstructions = []string{
+	"JMP", "JE", "JNE", "JG", "JL", "JGE", "JLE", "CALL",
+	"JZ", "JNZ", "JC", "JNC", "JO", "JS",
+	"JSG", "JSGE", "JSL", "JSLE",
+}
+
+// farCallPrefix starts the labels of the far call helpers the assembler emits
+const farCallPrefix = "__far_"
+
+// mainSection is the section of code loaded at the code origin, as opposed to
+// a bank
+const mainSection = -1
+
 type Assembler struct {
 	Program        []string
 	OpcodeCount    int
+	Layout         Layout
 	LabelAddresses map[string]int // label name to label address
-	JumpAddresses  map[int]string // jump location to label name
+	LabelBanks     map[string]int // label name to bank, for labels in banks
+	BankNames      []string       // bank names, in the order their banks are numbered
+	Banks          [][]uint8      // assembled contents of each bank
 	ParseMap       map[OpcodeKey]func(int, []string, string, Opcode) ([]uint8, error)
+
+	source  []string // the program followed by far call helpers
+	section int      // the bank being assembled, or mainSection
 }
 
-func NewAssembler(program []string) *Assembler {
+func NewAssembler(program []string, layout Layout) *Assembler {
 	labelAddresses := make(map[string]int)
-	jumpAddresses := make(map[int]string)
 	asm := &Assembler{
 		Program:        program,
+		Layout:         layout,
 		OpcodeCount:    0,
 		LabelAddresses: labelAddresses,
-		JumpAddresses:  jumpAddresses,
+		LabelBanks:     make(map[string]int),
 		ParseMap:       make(map[OpcodeKey]func(int, []string, string, Opcode) ([]uint8, error)),
 	}
 
@@ -35,6 +56,7 @@ func NewAssembler(program []string) *Assembler {
 		INST_A:    asm.parseA,
 		INST_V:    asm.parseV,
 		INST_R:    asm.parseR,
+		INST_VR:   asm.parseVR,
 		INST_NONE: asm.parseNone,
 	}
 
@@ -45,8 +67,13 @@ func NewAssembler(program []string) *Assembler {
 	return asm
 }
 
+// Assemble returns the code loaded at the code origin. Code and data placed in
+// banks are left in Banks.
 func (a *Assembler) Assemble() ([]uint8, error) {
-	a.firstPass()
+	a.source = append(slices.Clone(a.Program), a.farCallHelpers()...)
+	if err := a.firstPass(); err != nil {
+		return nil, err
+	}
 	bytecode, err := a.secondPass()
 	if err != nil {
 		return nil, err
@@ -54,10 +81,21 @@ func (a *Assembler) Assemble() ([]uint8, error) {
 	return bytecode, nil
 }
 
+// AssembleProgram assembles the code and banks into a program for EncodeBinary
+func (a *Assembler) AssembleProgram() (Program, error) {
+	code, err := a.Assemble()
+	if err != nil {
+		return Program{}, err
+	}
+	return Program{Layout: a.Layout, Code: code, Banks: a.Banks}, nil
+}
+
 // First pass goes through and fills out the labels
-func (a *Assembler) firstPass() {
+func (a *Assembler) firstPass() error {
 	opcodeCount := 0
-	for _, line := range a.Program {
+	bankCounts := []int{}
+	a.section = mainSection
+	for i, line := range a.source {
 		parts := strings.Fields(line)
 
 		// Skip empty lines
@@ -70,33 +108,101 @@ func (a *Assembler) firstPass() {
 			continue
 		}
 
+		var size int
+		switch {
+		// Directives switch sections and place data
+		case parts[0][0] == '.':
+			var err error
+			size, err = a.directive(i, parts)
+			if err != nil {
+				return err
+			}
+			if a.section != mainSection && a.section == len(bankCounts) {
+				bankCounts = append(bankCounts, 0)
+			}
+
 		// If the line is a label, add it to the label map
-		if len(parts) == 1 && parts[0][len(parts[0])-1] == ':' {
+		case len(parts) == 1 && parts[0][len(parts[0])-1] == ':':
 			labelName := parts[0][:len(parts[0])-1]
-			a.LabelAddresses[labelName] = opcodeCount + StoredMemorySize
+			if a.section == mainSection {
+				a.LabelAddresses[labelName] = opcodeCount + int(a.Layout.CodeOrigin)
+			} else {
+				a.LabelAddresses[labelName] = bankCounts[a.section] + int(a.Layout.BankWindow)
+				a.LabelBanks[labelName] = a.section
+			}
 			continue
-		}
 
-		opcodeName := parts[0]
+		case parts[0] == "FARCALL":
+			size = InstructionSizeMap[INST_AL]
 
-		jmpRegisters := []string{"JMP", "JE", "JNE", "JG", "JL", "JGE", "JLE", "CALL"}
-		if slices.Contains(jmpRegisters, opcodeName) && !validRegister(parts[1]) &&
-			!isANumber(parts[1]) {
-			a.JumpAddresses[opcodeCount+1] = parts[1]
+		default:
+			size = InstructionSizeMap[getInstructionType(parts)]
 		}
 
-		instructionType := getInstructionType(parts)
-		opcodeCount += InstructionSizeMap[instructionType]
+		if a.section == mainSection {
+			opcodeCount += size
+			if opcodeCount > a.Layout.CodeSpace() {
+				return NewAssemblerError(CODE_TOO_LARGE, i, 0, parts[0],
+					fmt.Sprintf("Code does not fit in the %d bytes at the code origin", a.Layout.CodeSpace()))
+			}
+		} else {
+			bankCounts[a.section] += size
+			if bankCounts[a.section] > a.Layout.BankSize {
+				return NewAssemblerError(CODE_TOO_LARGE, i, 0, parts[0],
+					fmt.Sprintf("Bank %s does not fit in the %d byte bank window", a.BankNames[a.section], a.Layout.BankSize))
+			}
+		}
 	}
 
 	a.OpcodeCount = opcodeCount
+	return nil
+}
+
+// directive handles a line starting with a dot, returning how many bytes it
+// places in the current section:
+//
+//	.bank name   assemble the lines that follow into the named bank
+//	.main        assemble the lines that follow at the code origin
+//	.byte v ...  place values, given the same way as instruction operands
+func (a *Assembler) directive(line int, parts []string) (int, error) {
+	switch parts[0] {
+	case ".bank":
+		if len(parts) != 2 {
+			return 0, NewAssemblerError(INVALID_OPERAND_COUNT, line, 0, parts[0], "Directive must have 1 operand")
+		}
+		if !a.Layout.Banked() {
+			return 0, NewAssemblerError(INVALID_DIRECTIVE, line, 0, parts[0], "The layout has no bank window")
+		}
+		a.section = slices.Index(a.BankNames, parts[1])
+		if a.section == -1 {
+			if len(a.BankNames) == MaxBanks {
+				return 0, NewAssemblerError(INVALID_DIRECTIVE, line, 0, parts[0], fmt.Sprintf("There can be at most %d banks", MaxBanks))
+			}
+			a.BankNames = append(a.BankNames, parts[1])
+			a.section = len(a.BankNames) - 1
+		}
+		return 0, nil
+
+	case ".main":
+		a.section = mainSection
+		return 0, nil
+
+	case ".byte":
+		if len(parts) < 2 {
+			return 0, NewAssemblerError(INVALID_OPERAND_COUNT, line, 0, parts[0], "Directive must have at least 1 operand")
+		}
+		return len(parts) - 1, nil
+	}
+	return 0, NewAssemblerError(INVALID_DIRECTIVE, line, 0, parts[0], "Unknown directive")
 }
 
 // Second pass goes through and fills out the instructions, returning the bytecode
-func (a Assembler) secondPass() ([]uint8, error) {
+func (a *Assembler) secondPass() ([]uint8, error) {
 	var bytecode []uint8
+	a.Banks = make([][]uint8, len(a.BankNames))
 
-	for i, line := range a.Program {
+	a.section = mainSection
+	for i, line := range a.source {
 		parts := strings.Fields(line)
 
 		// Skip empty lines
@@ -114,36 +220,123 @@ func (a Assembler) secondPass() ([]uint8, error) {
 			continue
 		}
 
-		opcodeName := parts[0]
-		instructionType := getInstructionType(parts)
-		instruction, ok := OpcodeMap[OpcodeKey{opcodeName, instructionType}]
-		if !ok {
-			return nil, NewAssemblerError(INVALID_OPCODE, i, 0, opcodeName, "Invalid opcode")
+		var bytes []uint8
+		var err error
+		switch {
+		case parts[0] == ".byte":
+			bytes, err = a.parseBytes(i, parts)
+		case parts[0][0] == '.':
+			_, err = a.directive(i, parts)
+		case parts[0] == "FARCALL":
+			bytes, err = a.parseFarCall(i, parts)
+		default:
+			bytes, err = a.parseInstruction(i, parts)
 		}
-
-		bytes, err := a.ParseMap[OpcodeKey{opcodeName, instructionType}](
-			i,
-			parts,
-			opcodeName,
-			instruction,
-		)
 		if err != nil {
 			return nil, err
 		}
 
-		bytecode = append(bytecode, bytes...)
+		if a.section == mainSection {
+			bytecode = append(bytecode, bytes...)
+		} else {
+			a.Banks[a.section] = append(a.Banks[a.section], bytes...)
+		}
+	}
+
+	return bytecode, nil
+}
+
+func (a *Assembler) parseInstruction(line int, parts []string) ([]uint8, error) {
+	opcodeName := parts[0]
+	instructionType := getInstructionType(parts)
+	instruction, ok := OpcodeMap[OpcodeKey{opcodeName, instructionType}]
+	if !ok {
+		return nil, NewAssemblerError(INVALID_OPCODE, line, 0, opcodeName, "Invalid opcode")
+	}
+
+	// Code can only jump into the bank it is in, other banks are not mapped.
+	// The far call helpers switch banks before calling into them.
+	if instructionType == INST_AL && line < len(a.Program) {
+		if bank, ok := a.LabelBanks[parts[1]]; ok && bank != a.section {
+			return nil, NewAssemblerError(INVALID_LABEL, line, instruction, opcodeName,
+				fmt.Sprintf("Label %s is in bank %s, use FARCALL", parts[1], a.BankNames[bank]))
+		}
 	}
 
-	for jumpAddress, labelName := range a.JumpAddresses {
-		labelAddress, ok := a.LabelAddresses[labelName]
+	return a.ParseMap[OpcodeKey{opcodeName, instructionType}](
+		line,
+		parts,
+		opcodeName,
+		instruction,
+	)
+}
+
+// parseBytes places the values of a .byte directive
+func (a *Assembler) parseBytes(line int, parts []string) ([]uint8, error) {
+	var bytes []uint8
+	for _, operand := range parts[1:] {
+		value, ok := a.parseValue(operand)
 		if !ok {
-			return nil, NewAssemblerError(INVALID_LABEL, 0, 0, labelName, "Invalid label")
+			return nil, NewAssemblerError(INVALID_VALUE, line, 0, parts[0], "Invalid value")
 		}
+		bytes = append(bytes, value)
+	}
+	return bytes, nil
+}
 
-		bytecode[jumpAddress] = uint8(labelAddress)
+// parseFarCall assembles FARCALL label as a call to the far call helper for a
+// label in a bank
+func (a *Assembler) parseFarCall(line int, parts []string) ([]uint8, error) {
+	if len(parts) != 2 {
+		return nil, NewAssemblerError(INVALID_OPERAND_COUNT, line, OP_CALL_A, parts[0], "Instruction must have 1 operand")
 	}
+	if _, ok := a.LabelBanks[parts[1]]; !ok {
+		return nil, NewAssemblerError(INVALID_LABEL, line, OP_CALL_A, parts[0], "Label is not in a bank")
+	}
+	helper := a.LabelAddresses[farCallPrefix+parts[1]]
+	return []uint8{uint8(OP_CALL_A), uint8(helper), uint8(helper >> 8)}, nil
+}
 
-	return bytecode, nil
+// farCallHelpers returns a helper for each label that is far called, placed at
+// the end of the code at the code origin so that it is mapped whichever bank
+// is selected. A helper switches to the label's code bank, calls it and
+// switches back to the caller's bank. It saves R3 on the stack while it works,
+// so every register and the stack are as the caller and callee left them.
+func (a *Assembler) farCallHelpers() []string {
+	var targets []string
+	for _, line := range a.Program {
+		parts := strings.Fields(line)
+		if len(parts) == 2 && parts[0] == "FARCALL" && !slices.Contains(targets, parts[1]) {
+			targets = append(targets, parts[1])
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	codeBank := BankPort + bankCodeRegister
+	helpers := []string{".main"}
+	for _, target := range targets {
+		helpers = append(helpers,
+			farCallPrefix+target+":",
+			"PUSH R3",
+			fmt.Sprintf("IN R3 %d", codeBank),
+			"PUSH R3",
+			"LOAD R3 ^"+target,
+			fmt.Sprintf("OUT %d R3", codeBank),
+			// Restore R3 from under the saved bank for the callee
+			"LOADSP R3 1",
+			"CALL "+target,
+			"PUSH R3",
+			"LOADSP R3 1",
+			fmt.Sprintf("OUT %d R3", codeBank),
+			"POP R3",
+			// Drop the saved bank and R3
+			"ADDSP 2",
+			"RET",
+		)
+	}
+	return helpers
 }
 
 func (a *Assembler) parseRR(
@@ -197,19 +390,11 @@ func (a *Assembler) parseRV(
 			"Invalid register",
 		)
 	}
-	value, err := strconv.Atoi(parts[2])
-	if err != nil || !validValue(value) {
-		if convErr, ok := err.(*strconv.NumError); ok {
-			if len(convErr.Num) == 1 && parts[2][0] >= 0 && parts[2][0] <= 255 {
-				value = int(parts[2][0])
-			} else {
-				return nil, NewAssemblerError(INVALID_VALUE, line, opcode, opcodeName, "Invalid value")
-			}
-		} else {
-			return nil, NewAssemblerError(INVALID_VALUE, line, opcode, opcodeName, "Invalid value")
-		}
+	value, ok := a.parseValue(parts[2])
+	if !ok {
+		return nil, NewAssemblerError(INVALID_VALUE, line, opcode, opcodeName, "Invalid value")
 	}
-	return []uint8{uint8(opcode), uint8(RegisterMap[parts[1]]), uint8(value)}, nil
+	return []uint8{uint8(opcode), uint8(RegisterMap[parts[1]]), value}, nil
 }
 
 func (a *Assembler) parseRA(
@@ -236,11 +421,11 @@ func (a *Assembler) parseRA(
 			"Invalid register",
 		)
 	}
-	address, err := strconv.Atoi(parts[2])
+	address, err := parseNumber(parts[2])
 	if err != nil || !validAddress(address) {
 		return nil, NewAssemblerError(INVALID_ADDRESS, line, opcode, opcodeName, "Invalid address")
 	}
-	return []uint8{uint8(opcode), uint8(RegisterMap[parts[1]]), uint8(address)}, nil
+	return []uint8{uint8(opcode), uint8(RegisterMap[parts[1]]), uint8(address), uint8(address >> 8)}, nil
 }
 
 func (a *Assembler) parseRL(
@@ -262,6 +447,7 @@ func (a *Assembler) parseRL(
 		return []uint8{
 				uint8(opcode),
 				0,
+				0,
 			}, NewAssemblerError(
 				INVALID_LABEL,
 				line,
@@ -270,7 +456,8 @@ func (a *Assembler) parseRL(
 				"Invalid label",
 			)
 	}
-	return []uint8{uint8(opcode), uint8(a.LabelAddresses[parts[1]])}, nil
+	labelAddress := a.LabelAddresses[parts[1]]
+	return []uint8{uint8(opcode), uint8(labelAddress), uint8(labelAddress >> 8)}, nil
 }
 
 func (a *Assembler) parseAV(
@@ -289,25 +476,17 @@ func (a *Assembler) parseAV(
 		)
 	}
 
-	address, err := strconv.Atoi(parts[1])
+	address, err := parseNumber(parts[1])
 	if err != nil || !validAddress(address) {
 		return nil, NewAssemblerError(INVALID_ADDRESS, line, opcode, opcodeName, "Invalid address")
 	}
 
-	value, err := strconv.Atoi(parts[2])
-	if err != nil || !validValue(value) {
-		if convErr, ok := err.(*strconv.NumError); ok {
-			if len(convErr.Num) == 1 && parts[2][0] >= 0 && parts[2][0] <= 255 {
-				value = int(parts[2][0])
-			} else {
-				return nil, NewAssemblerError(INVALID_VALUE, line, opcode, opcodeName, "Invalid value")
-			}
-		} else {
-			return nil, NewAssemblerError(INVALID_VALUE, line, opcode, opcodeName, "Invalid value")
-		}
+	value, ok := a.parseValue(parts[2])
+	if !ok {
+		return nil, NewAssemblerError(INVALID_VALUE, line, opcode, opcodeName, "Invalid value")
 	}
 
-	return []uint8{uint8(opcode), uint8(address), uint8(value)}, nil
+	return []uint8{uint8(opcode), uint8(address), uint8(address >> 8), value}, nil
 }
 
 func (a *Assembler) parseAL(
@@ -329,6 +508,7 @@ func (a *Assembler) parseAL(
 		return []uint8{
 				uint8(opcode),
 				0,
+				0,
 			}, NewAssemblerError(
 				INVALID_LABEL,
 				line,
@@ -337,7 +517,8 @@ func (a *Assembler) parseAL(
 				"Invalid label",
 			)
 	}
-	return []uint8{uint8(opcode), uint8(a.LabelAddresses[parts[1]])}, nil
+	labelAddress := a.LabelAddresses[parts[1]]
+	return []uint8{uint8(opcode), uint8(labelAddress), uint8(labelAddress >> 8)}, nil
 }
 
 func (a *Assembler) parseA(
@@ -355,11 +536,11 @@ func (a *Assembler) parseA(
 			"Instruction must have 1 operand",
 		)
 	}
-	address, err := strconv.Atoi(parts[1])
+	address, err := parseNumber(parts[1])
 	if err != nil || !validAddress(address) {
 		return nil, NewAssemblerError(INVALID_ADDRESS, line, opcode, opcodeName, "Invalid address")
 	}
-	return []uint8{uint8(opcode), uint8(address)}, nil
+	return []uint8{uint8(opcode), uint8(address), uint8(address >> 8)}, nil
 }
 
 func (a *Assembler) parseV(
@@ -378,20 +559,43 @@ func (a *Assembler) parseV(
 		)
 	}
 
-	value, err := strconv.Atoi(parts[1])
-	if err != nil || !validValue(value) {
-		if convErr, ok := err.(*strconv.NumError); ok {
-			if len(convErr.Num) == 1 && parts[2][0] >= 0 && parts[2][0] <= 255 {
-				value = int(parts[2][0])
-			} else {
-				return nil, NewAssemblerError(INVALID_VALUE, line, opcode, opcodeName, "Invalid value")
-			}
-		} else {
-			return nil, NewAssemblerError(INVALID_VALUE, line, opcode, opcodeName, "Invalid value")
-		}
+	value, ok := a.parseValue(parts[1])
+	if !ok {
+		return nil, NewAssemblerError(INVALID_VALUE, line, opcode, opcodeName, "Invalid value")
 	}
 
-	return []uint8{uint8(opcode), uint8(value)}, nil
+	return []uint8{uint8(opcode), value}, nil
+}
+
+func (a *Assembler) parseVR(
+	line int,
+	parts []string,
+	opcodeName string,
+	opcode Opcode,
+) ([]uint8, error) {
+	if len(parts) != 3 {
+		return nil, NewAssemblerError(
+			INVALID_OPERAND_COUNT,
+			line,
+			opcode,
+			opcodeName,
+			"Instruction must have 2 operands",
+		)
+	}
+	value, ok := a.parseValue(parts[1])
+	if !ok {
+		return nil, NewAssemblerError(INVALID_VALUE, line, opcode, opcodeName, "Invalid value")
+	}
+	if !validRegister(parts[2]) {
+		return nil, NewAssemblerError(
+			INVALID_REGISTER,
+			line,
+			opcode,
+			opcodeName,
+			"Invalid register",
+		)
+	}
+	return []uint8{uint8(opcode), value, uint8(RegisterMap[parts[2]])}, nil
 }
 
 func (a *Assembler) parseR(
@@ -446,8 +650,38 @@ func validRegister(reg string) bool {
 	return true
 }
 
+// validValue accepts unsigned bytes and negative numbers that fit in a signed
+// byte, which are stored as two's complement
 func validValue(value int) bool {
-	return value >= 0 && value <= 255
+	return value >= -128 && value <= 255
+}
+
+// parseValue parses a value operand, either a number, a single character, the
+// low (<label) or high (>label) byte of a label's address, or the number of the
+// bank a label is in (^label)
+func (a *Assembler) parseValue(operand string) (uint8, bool) {
+	value, err := parseNumber(operand)
+	if err == nil {
+		return uint8(value), validValue(value)
+	}
+	if len(operand) == 1 {
+		return operand[0], true
+	}
+	if bankLabel, ok := strings.CutPrefix(operand, "^"); ok {
+		bank, ok := a.LabelBanks[bankLabel]
+		return uint8(bank), ok
+	}
+	if operand[0] == '<' || operand[0] == '>' {
+		labelAddress, ok := a.LabelAddresses[operand[1:]]
+		if !ok {
+			return 0, false
+		}
+		if operand[0] == '>' {
+			labelAddress >>= 8
+		}
+		return uint8(labelAddress), true
+	}
+	return 0, false
 }
 
 func validAddress(address int) bool {
@@ -455,10 +689,19 @@ func validAddress(address int) bool {
 }
 
 func isANumber(s string) bool {
-	_, err := strconv.Atoi(s)
+	_, err := parseNumber(s)
 	return err == nil
 }
 
+// parseNumber parses a decimal number, or a hexadecimal one prefixed with 0x
+func parseNumber(s string) (int, error) {
+	if hex, ok := strings.CutPrefix(s, "0x"); ok {
+		value, err := strconv.ParseUint(hex, 16, 16)
+		return int(value), err
+	}
+	return strconv.Atoi(s)
+}
+
 func getInstructionType(parts []string) InstructionType {
 	if len(parts) == 1 {
 		return INST_NONE
@@ -468,12 +711,11 @@ func getInstructionType(parts []string) InstructionType {
 		if validRegister(parts[1]) {
 			return INST_R
 		} else {
-			vInstructions := []string{"PRINT", "PUSH"}
+			vInstructions := []string{"PRINT", "PUSH", "PRINTC", "ADDSP", "SUBSP"}
 			if slices.Contains(vInstructions, parts[0]) {
 				return INST_V
 			} else {
-				lInstructions := []string{"CALL", "JE", "JNE", "JG", "JL", "JGE", "JLE", "JMP"}
-				if slices.Contains(lInstructions, parts[0]) && !isANumber(parts[1]) {
+				if slices.Contains(jumpInstructions, parts[0]) && !isANumber(parts[1]) {
 					return INST_AL
 				} else {
 					return INST_A
@@ -495,7 +737,11 @@ func getInstructionType(parts []string) InstructionType {
 				}
 			}
 		} else {
-			return INST_AV
+			if validRegister(parts[2]) {
+				return INST_VR
+			} else {
+				return INST_AV
+			}
 		}
 	}
 
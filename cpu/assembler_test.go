@@ -1,6 +1,11 @@
 package cpu
 
-import "testing"
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
 
 func TestAssembler(t *testing.T) {
 	program := []string{
@@ -69,9 +74,27 @@ func TestAssembler(t *testing.T) {
 		"PRINT R1",
 		"PRINTS 10",
 		"HLT",
+		"READ R1",
+		"READS 10",
+		"PRINTC R1",
+		"PRINTC A",
+		"JZ prelabel",
+		"JNZ 5",
+		"JC R1",
+		"JNC prelabel",
+		"JO 5",
+		"JS R1",
+		"ADC R1 R2",
+		"ADC R1 1",
+		"SBB R1 R2",
+		"SBB R1 1",
+		"LOADSP R1 2",
+		"STORESP R1 2",
+		"ADDSP 3",
+		"SUBSP 3",
 	}
 
-	asm := NewAssembler(program)
+	asm := NewAssembler(program, DefaultLayout)
 
 	bytecode, err := asm.Assemble()
 	if err != nil {
@@ -81,9 +104,9 @@ func TestAssembler(t *testing.T) {
 	expected := []uint8{
 		uint8(OP_LOAD_RV), 1, 1,
 		uint8(OP_LOAD_RR), 1, 2,
-		uint8(OP_LOADM_RA), 1, 1,
-		uint8(OP_STORE_RA), 1, 1,
-		uint8(OP_STORE_AV), 1, 2,
+		uint8(OP_LOADM_RA), 1, 1, 0,
+		uint8(OP_STORE_RA), 1, 1, 0,
+		uint8(OP_STORE_AV), 1, 0, 2,
 		uint8(OP_STORE_RR), 1, 2,
 		uint8(OP_ADD_RR), 1, 2,
 		uint8(OP_ADD_RV), 1, 1,
@@ -112,36 +135,58 @@ func TestAssembler(t *testing.T) {
 		uint8(OP_POP_R), 1,
 		uint8(OP_CMP_RR), 1, 2,
 		uint8(OP_CMP_RV), 1, 1,
-		uint8(OP_JMP_A), 3 + CodeMemoryStart,
-		uint8(OP_JMP_A), 97 + CodeMemoryStart,
-		uint8(OP_JMP_A), 5,
+		uint8(OP_JMP_A), 3 + CodeMemoryStart, 0,
+		uint8(OP_JMP_A), 103 + CodeMemoryStart, 0,
+		uint8(OP_JMP_A), 5, 0,
 		uint8(OP_JMP_R), 1,
-		uint8(OP_JE_A), 3 + CodeMemoryStart,
-		uint8(OP_JE_A), 5,
+		uint8(OP_JE_A), 3 + CodeMemoryStart, 0,
+		uint8(OP_JE_A), 5, 0,
 		uint8(OP_JE_R), 1,
-		uint8(OP_JNE_A), 3 + CodeMemoryStart,
-		uint8(OP_JNE_A), 5,
+		uint8(OP_JNE_A), 3 + CodeMemoryStart, 0,
+		uint8(OP_JNE_A), 5, 0,
 		uint8(OP_JNE_R), 1,
-		uint8(OP_JG_A), 3 + CodeMemoryStart,
-		uint8(OP_JG_A), 5,
+		uint8(OP_JG_A), 3 + CodeMemoryStart, 0,
+		uint8(OP_JG_A), 5, 0,
 		uint8(OP_JG_R), 1,
-		uint8(OP_JGE_A), 3 + CodeMemoryStart,
-		uint8(OP_JGE_A), 5,
+		uint8(OP_JGE_A), 3 + CodeMemoryStart, 0,
+		uint8(OP_JGE_A), 5, 0,
 		uint8(OP_JGE_R), 1,
-		uint8(OP_JL_A), 3 + CodeMemoryStart,
-		uint8(OP_JL_A), 5,
+		uint8(OP_JL_A), 3 + CodeMemoryStart, 0,
+		uint8(OP_JL_A), 5, 0,
 		uint8(OP_JL_R), 1,
-		uint8(OP_JLE_A), 3 + CodeMemoryStart,
-		uint8(OP_JLE_A), 5,
+		uint8(OP_JLE_A), 3 + CodeMemoryStart, 0,
+		uint8(OP_JLE_A), 5, 0,
 		uint8(OP_JLE_R), 1,
-		uint8(OP_CALL_A), 3 + CodeMemoryStart,
-		uint8(OP_CALL_A), 5,
+		uint8(OP_CALL_A), 3 + CodeMemoryStart, 0,
+		uint8(OP_CALL_A), 5, 0,
 		uint8(OP_CALL_R), 1,
 		uint8(OP_RET_NONE),
 		uint8(OP_PRINT_V), 1,
 		uint8(OP_PRINT_R), 1,
-		uint8(OP_PRINTS_A), 10,
+		uint8(OP_PRINTS_A), 10, 0,
 		uint8(OP_HLT_NONE),
+		uint8(OP_READ_R), 1,
+		uint8(OP_READS_A), 10, 0,
+		uint8(OP_PRINTC_R), 1,
+		uint8(OP_PRINTC_V), 'A',
+		uint8(OP_JZ_A), 3 + CodeMemoryStart, 0,
+		uint8(OP_JNZ_A), 5, 0,
+		uint8(OP_JC_R), 1,
+		uint8(OP_JNC_A), 3 + CodeMemoryStart, 0,
+		uint8(OP_JO_A), 5, 0,
+		uint8(OP_JS_R), 1,
+		uint8(OP_ADC_RR), 1, 2,
+		uint8(OP_ADC_RV), 1, 1,
+		uint8(OP_SBB_RR), 1, 2,
+		uint8(OP_SBB_RV), 1, 1,
+		uint8(OP_LOADSP_RV), 1, 2,
+		uint8(OP_STORESP_RV), 1, 2,
+		uint8(OP_ADDSP_V), 3,
+		uint8(OP_SUBSP_V), 3,
+	}
+
+	if len(bytecode) != len(expected) {
+		t.Fatalf("Expected %d bytes of bytecode, got %d", len(expected), len(bytecode))
 	}
 
 	for i, b := range bytecode {
@@ -150,3 +195,179 @@ func TestAssembler(t *testing.T) {
 		}
 	}
 }
+
+func TestAssemblerNegativeValues(t *testing.T) {
+	asm := NewAssembler([]string{
+		"LOAD R0 -1",
+		"PUSH -128",
+		"STORE 0 -2",
+	}, DefaultLayout)
+
+	bytecode, err := asm.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err.Error())
+	}
+
+	expected := []uint8{
+		uint8(OP_LOAD_RV), 0, 255,
+		uint8(OP_PUSH_V), 128,
+		uint8(OP_STORE_AV), 0, 0, 254,
+	}
+
+	if !slices.Equal(bytecode, expected) {
+		t.Errorf("Expected bytecode %v, got %v", expected, bytecode)
+	}
+
+	for _, line := range []string{"LOAD R0 -129", "LOAD R0 256", "LOAD R0 0x100"} {
+		if _, err := NewAssembler([]string{line}, DefaultLayout).Assemble(); err == nil {
+			t.Errorf("Expected %q to fail to assemble", line)
+		}
+	}
+}
+
+func TestAssemblerAddresses(t *testing.T) {
+	asm := NewAssembler([]string{
+		"LOADM R0 0x1234",
+		"STORE 300 7",
+		"JMP 65535",
+	}, DefaultLayout)
+
+	bytecode, err := asm.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err.Error())
+	}
+
+	expected := []uint8{
+		uint8(OP_LOADM_RA), 0, 0x34, 0x12,
+		uint8(OP_STORE_AV), 0x2C, 0x01, 7,
+		uint8(OP_JMP_A), 0xFF, 0xFF,
+	}
+
+	if !slices.Equal(bytecode, expected) {
+		t.Errorf("Expected bytecode %v, got %v", expected, bytecode)
+	}
+
+	if _, err := NewAssembler([]string{"JMP 65536"}, DefaultLayout).Assemble(); err == nil {
+		t.Errorf("Expected an address past the end of memory to fail to assemble")
+	}
+}
+
+func TestAssemblerLabelBytes(t *testing.T) {
+	asm := NewAssembler([]string{
+		"STORE 0xFEE0 <handler",
+		"STORE 0xFEE1 >handler",
+		"HLT",
+		"handler:",
+		"IRET",
+	}, DefaultLayout)
+
+	bytecode, err := asm.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err.Error())
+	}
+
+	// handler sits after two 4 byte stores and a HLT
+	handler := StoredMemorySize + 9
+	expected := []uint8{
+		uint8(OP_STORE_AV), 0xE0, 0xFE, uint8(handler),
+		uint8(OP_STORE_AV), 0xE1, 0xFE, uint8(handler >> 8),
+		uint8(OP_HLT_NONE),
+		uint8(OP_IRET_NONE),
+	}
+
+	if !slices.Equal(bytecode, expected) {
+		t.Errorf("Expected bytecode %v, got %v", expected, bytecode)
+	}
+
+	if _, err := NewAssembler([]string{"PUSH <missing"}, DefaultLayout).Assemble(); err == nil {
+		t.Errorf("Expected an unknown label to fail to assemble")
+	}
+}
+
+func TestAssemblerBanks(t *testing.T) {
+	asm := NewAssembler([]string{
+		"FARCALL far",
+		"LOAD R0 ^data",
+		"HLT",
+		".bank code",
+		"JMP here",
+		"here:",
+		"far:",
+		"RET",
+		".bank tables",
+		".byte 1 -1 A <data",
+		"data:",
+		".byte 0x10",
+		".main",
+		"JMP 0",
+	}, bankedLayout)
+
+	code, err := asm.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	if asm.LabelAddresses["far"] != 0x8003 || asm.LabelBanks["far"] != 0 {
+		t.Errorf("Expected far at 0x8003 in bank 0, got %#x in bank %d", asm.LabelAddresses["far"], asm.LabelBanks["far"])
+	}
+	if asm.LabelAddresses["data"] != 0x8004 || asm.LabelBanks["data"] != 1 {
+		t.Errorf("Expected data at 0x8004 in bank 1, got %#x in bank %d", asm.LabelAddresses["data"], asm.LabelBanks["data"])
+	}
+	if _, ok := asm.LabelBanks["here"]; !ok {
+		t.Errorf("Expected here to be in a bank")
+	}
+
+	// The far call goes through the helper placed after the rest of the code,
+	// including the code after .main
+	helper := StoredMemorySize + 10
+	expected := []uint8{
+		uint8(OP_CALL_A), uint8(helper), uint8(helper >> 8),
+		uint8(OP_LOAD_RV), 0, 1,
+		uint8(OP_HLT_NONE),
+		uint8(OP_JMP_A), 0, 0,
+	}
+	if !slices.Equal(code[:len(expected)], expected) {
+		t.Errorf("Expected code %v, got %v", expected, code[:len(expected)])
+	}
+	if asm.LabelAddresses["__far_far"] != helper {
+		t.Errorf("Expected the helper at %d, got %d", helper, asm.LabelAddresses["__far_far"])
+	}
+
+	if !slices.Equal(asm.BankNames, []string{"code", "tables"}) {
+		t.Errorf("Expected banks code and tables, got %v", asm.BankNames)
+	}
+	if !slices.Equal(asm.Banks[0], []uint8{uint8(OP_JMP_A), 0x03, 0x80, uint8(OP_RET_NONE)}) {
+		t.Errorf("Unexpected code bank %v", asm.Banks[0])
+	}
+	if !slices.Equal(asm.Banks[1], []uint8{1, 255, 'A', 0x04, 0x10}) {
+		t.Errorf("Unexpected tables bank %v", asm.Banks[1])
+	}
+}
+
+func TestAssemblerBankErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  Layout
+		program []string
+		errType AssemblerErrorType
+	}{
+		{"no bank window", DefaultLayout, []string{".bank code"}, INVALID_DIRECTIVE},
+		{"unknown directive", bankedLayout, []string{".org 5"}, INVALID_DIRECTIVE},
+		{"jump into a bank", bankedLayout, []string{"CALL far", ".bank code", "far:", "RET"}, INVALID_LABEL},
+		{"jump between banks", bankedLayout, []string{".bank a", "JMP far", ".bank b", "far:", "RET"}, INVALID_LABEL},
+		{"far call outside the banks", bankedLayout, []string{"FARCALL near", "near:", "RET"}, INVALID_LABEL},
+		{"bank of a label outside the banks", bankedLayout, []string{"LOAD R0 ^near", "near:"}, INVALID_VALUE},
+		{"empty byte directive", bankedLayout, []string{".byte"}, INVALID_OPERAND_COUNT},
+		{"bank overflow", bankedLayout, []string{".bank big", ".byte " + strings.Repeat("0 ", 0x101)}, CODE_TOO_LARGE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAssembler(tt.program, tt.layout).Assemble()
+			var asmErr *AssemblerError
+			if !errors.As(err, &asmErr) || asmErr.Type != tt.errType {
+				t.Errorf("Expected a %s error, got %v", tt.errType, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package cpu
+
+import "fmt"
+
+// Ports of the bank registers. Far call helpers emitted by the assembler switch
+// banks through these, so the registers are always attached here.
+const (
+	BankPort          = 0x30
+	BankRegistersSize = 2
+)
+
+// Bank registers, as offsets from BankPort
+const (
+	bankCodeRegister = iota // Bank instructions are fetched from
+	bankDataRegister        // Bank data is read from and written to
+)
+
+// MaxBanks is the most banks a bank register can select
+const MaxBanks = 256
+
+// Banks is a bank switching controller. It is mapped over the layout's bank
+// window and shows one of its banks there. Instructions are fetched from the
+// code bank and data is read and written in the data bank, which are selected
+// separately, so code running in one bank can use tables in another.
+type Banks struct {
+	Data [][]uint8
+
+	code int
+	data int
+}
+
+// NewBanks creates count banks of size bytes each, with bank 0 selected for
+// both code and data
+func NewBanks(count, size int) *Banks {
+	data := make([][]uint8, count)
+	for i := range data {
+		data[i] = make([]uint8, size)
+	}
+	return &Banks{Data: data}
+}
+
+// Load copies the contents of assembled banks into the first banks
+func (b *Banks) Load(banks [][]uint8) error {
+	if len(banks) > len(b.Data) {
+		return fmt.Errorf("%d banks do not fit in %d", len(banks), len(b.Data))
+	}
+	for i, bank := range banks {
+		if len(bank) > len(b.Data[i]) {
+			return fmt.Errorf("bank %d has %d bytes, more than the %d byte window", i, len(bank), len(b.Data[i]))
+		}
+		copy(b.Data[i], bank)
+	}
+	return nil
+}
+
+// CodeBank returns the bank instructions are fetched from
+func (b *Banks) CodeBank() int {
+	return b.code
+}
+
+// DataBank returns the bank data is read from and written to
+func (b *Banks) DataBank() int {
+	return b.data
+}
+
+func (b *Banks) Read(offset uint16) (uint8, error) {
+	return b.access(b.data, offset, "read")
+}
+
+func (b *Banks) Write(offset uint16, value uint8) error {
+	if _, err := b.access(b.data, offset, "write"); err != nil {
+		return err
+	}
+	b.Data[b.data][offset] = value
+	return nil
+}
+
+// Fetch reads an instruction byte from the code bank
+func (b *Banks) Fetch(offset uint16) (uint8, error) {
+	return b.access(b.code, offset, "fetch")
+}
+
+func (b *Banks) access(bank int, offset uint16, access string) (uint8, error) {
+	if int(offset) >= len(b.Data[bank]) {
+		return 0, fmt.Errorf("%w: %s at offset %d of bank %d", MEMORY_OUT_OF_BOUNDS, access, offset, bank)
+	}
+	return b.Data[bank][offset], nil
+}
+
+// Registers returns the device that selects the code and data banks, to be
+// attached at BankPort
+func (b *Banks) Registers() Device {
+	return bankRegisters{b}
+}
+
+type bankRegisters struct {
+	banks *Banks
+}
+
+func (r bankRegisters) Read(offset uint16) (uint8, error) {
+	switch offset {
+	case bankCodeRegister:
+		return uint8(r.banks.code), nil
+	case bankDataRegister:
+		return uint8(r.banks.data), nil
+	}
+	return 0, fmt.Errorf("%w: read from bank register %d", BUS_ERROR, offset)
+}
+
+func (r bankRegisters) Write(offset uint16, value uint8) error {
+	if int(value) >= len(r.banks.Data) {
+		return fmt.Errorf("%w: no bank %d, there are %d", BUS_ERROR, value, len(r.banks.Data))
+	}
+	switch offset {
+	case bankCodeRegister:
+		r.banks.code = int(value)
+	case bankDataRegister:
+		r.banks.data = int(value)
+	default:
+		return fmt.Errorf("%w: write to bank register %d", BUS_ERROR, offset)
+	}
+	return nil
+}
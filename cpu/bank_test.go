@@ -0,0 +1,137 @@
+package cpu
+
+import (
+	"errors"
+	"testing"
+)
+
+// bankedLayout is the default layout with a small bank window
+var bankedLayout = Layout{
+	DataSize:   StoredMemorySize,
+	CodeOrigin: CodeMemoryStart,
+	TotalSize:  TotalMemorySize,
+	StackTop:   StackTop,
+	StackSize:  StackSize,
+	BankWindow: 0x8000,
+	BankSize:   0x100,
+}
+
+func TestBanks(t *testing.T) {
+	banks := NewBanks(3, 0x10)
+	decoder := NewAddressDecoder()
+	if err := decoder.Map(0x8000, 0x10, banks); err != nil {
+		t.Fatalf("Unexpected error mapping banks: %s", err)
+	}
+	registers := banks.Registers()
+
+	banks.Data[1][4] = 11
+	banks.Data[2][4] = 22
+
+	if err := registers.Write(bankCodeRegister, 1); err != nil {
+		t.Fatalf("Unexpected error selecting the code bank: %s", err)
+	}
+	if err := registers.Write(bankDataRegister, 2); err != nil {
+		t.Fatalf("Unexpected error selecting the data bank: %s", err)
+	}
+
+	if value, err := decoder.Fetch(0x8004); err != nil || value != 11 {
+		t.Errorf("Expected to fetch 11 from the code bank, got %d (%v)", value, err)
+	}
+	if value, err := decoder.Read(0x8004); err != nil || value != 22 {
+		t.Errorf("Expected to read 22 from the data bank, got %d (%v)", value, err)
+	}
+
+	if err := decoder.Write(0x8005, 33); err != nil {
+		t.Errorf("Unexpected error writing the data bank: %s", err)
+	}
+	if banks.Data[2][5] != 33 || banks.Data[1][5] != 0 {
+		t.Errorf("Expected the write to go to the data bank only")
+	}
+
+	if value, err := registers.Read(bankCodeRegister); err != nil || value != 1 {
+		t.Errorf("Expected to read back code bank 1, got %d (%v)", value, err)
+	}
+
+	if err := registers.Write(bankDataRegister, 3); !errors.Is(err, BUS_ERROR) {
+		t.Errorf("Expected a bus error selecting a missing bank, got %v", err)
+	}
+	if banks.DataBank() != 2 {
+		t.Errorf("Expected a missing bank to leave the data bank alone, got %d", banks.DataBank())
+	}
+
+	if err := banks.Load(make([][]uint8, 4)); err == nil {
+		t.Errorf("Expected loading more banks than there are to fail")
+	}
+	if err := banks.Load([][]uint8{make([]uint8, 0x11)}); err == nil {
+		t.Errorf("Expected loading a bank larger than the window to fail")
+	}
+}
+
+func TestFarCall(t *testing.T) {
+	asm := NewAssembler([]string{
+		"LOAD R0 5",
+		"LOAD R3 9",
+		"FARCALL add",
+		"LOAD R1 ^table",
+		"OUT 49 R1",
+		"LOADM R2 0x8001",
+		"HLT",
+
+		".bank adder",
+		"add:",
+		"ADD R0 R3",
+		// Far calls work from one bank to another too
+		"FARCALL triple",
+		"RET",
+
+		".bank multiplier",
+		"triple:",
+		"MUL R0 3",
+		"LOAD R3 77",
+		"RET",
+
+		".bank tables",
+		"table:",
+		".byte 42 7",
+	}, bankedLayout)
+
+	program, err := asm.AssembleProgram()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	cpu := NewCPU(bankedLayout)
+	mem := NewMemory(bankedLayout)
+	mem.LoadCode(program.Code)
+
+	banks := NewBanks(len(program.Banks), bankedLayout.BankSize)
+	if err := banks.Load(program.Banks); err != nil {
+		t.Fatalf("Unexpected error loading banks: %s", err)
+	}
+	bus := NewAddressDecoder()
+	bus.SetFallback(mem)
+	if err := bus.Map(bankedLayout.BankWindow, bankedLayout.BankSize, banks); err != nil {
+		t.Fatalf("Unexpected error mapping banks: %s", err)
+	}
+	if err := cpu.MapPorts(BankPort, BankRegistersSize, banks.Registers()); err != nil {
+		t.Fatalf("Unexpected error mapping bank registers: %s", err)
+	}
+
+	if err := cpu.Execute(bus); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// The callee saw R3 from the caller, and the caller sees R3 from the callee
+	if cpu.Registers[0] != 42 || cpu.Registers[3] != 77 {
+		t.Errorf("Expected R0 42 and R3 77, got %v", cpu.Registers)
+	}
+	if cpu.Registers[2] != 7 {
+		t.Errorf("Expected to load 7 from the table bank, got %d", cpu.Registers[2])
+	}
+	if banks.CodeBank() != 0 || banks.DataBank() != 2 {
+		t.Errorf("Expected code bank 0 restored and data bank 2, got %d and %d", banks.CodeBank(), banks.DataBank())
+	}
+	if cpu.StackDepth() != 0 {
+		t.Errorf("Expected far calls to leave the stack empty, got depth %d", cpu.StackDepth())
+	}
+}
@@ -0,0 +1,151 @@
+package cpu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// binaryMagic starts a compiled binary with a header, followed by a version
+// byte. Binaries from before layouts were configurable are bare code and don't
+// have one.
+var binaryMagic = []byte("CPU")
+
+// Header versions. Version 1 only has the layout. Version 2 adds the bank
+// window, and the code is followed by the banks, each prefixed with its size.
+const (
+	binaryVersionLayout = 1
+	binaryVersionBanks  = 2
+)
+
+// binaryHeader is the layout as stored after the magic, little-endian
+type binaryHeader struct {
+	DataSize   uint16
+	CodeOrigin uint16
+	TotalSize  uint32
+	StackTop   uint16
+	StackSize  uint16
+}
+
+// binaryBankHeader follows binaryHeader from version 2
+type binaryBankHeader struct {
+	BankWindow uint16
+	BankSize   uint16
+	BankCount  uint16
+	CodeSize   uint16
+}
+
+// Program is a compiled program: the layout it was assembled for, the code
+// loaded at the code origin and the contents of its banks
+type Program struct {
+	Layout Layout
+	Code   []uint8
+	Banks  [][]uint8
+}
+
+// EncodeBinary writes a program with a header recording its layout
+func EncodeBinary(program Program) []uint8 {
+	layout := program.Layout
+
+	var b bytes.Buffer
+	b.Write(binaryMagic)
+	b.WriteByte(binaryVersionBanks)
+	binary.Write(&b, binary.LittleEndian, binaryHeader{
+		DataSize:   uint16(layout.DataSize),
+		CodeOrigin: layout.CodeOrigin,
+		TotalSize:  uint32(layout.TotalSize),
+		StackTop:   layout.StackTop,
+		StackSize:  uint16(layout.StackSize),
+	})
+	binary.Write(&b, binary.LittleEndian, binaryBankHeader{
+		BankWindow: layout.BankWindow,
+		BankSize:   uint16(layout.BankSize),
+		BankCount:  uint16(len(program.Banks)),
+		CodeSize:   uint16(len(program.Code)),
+	})
+	b.Write(program.Code)
+	for _, bank := range program.Banks {
+		binary.Write(&b, binary.LittleEndian, uint16(len(bank)))
+		b.Write(bank)
+	}
+	return b.Bytes()
+}
+
+// DecodeBinary reads a compiled binary back into a program. Binaries without a
+// header are taken to be code for DefaultLayout.
+func DecodeBinary(data []uint8) (Program, error) {
+	if !bytes.HasPrefix(data, binaryMagic) {
+		return checkCodeSize(Program{Layout: DefaultLayout, Code: data})
+	}
+
+	r := bytes.NewReader(data[len(binaryMagic):])
+	version, err := r.ReadByte()
+	if err != nil {
+		return Program{}, errors.New("binary header is truncated")
+	}
+	if version != binaryVersionLayout && version != binaryVersionBanks {
+		return Program{}, fmt.Errorf("binary has unknown version %d", version)
+	}
+
+	var header binaryHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return Program{}, errors.New("binary header is truncated")
+	}
+	program := Program{Layout: Layout{
+		DataSize:   int(header.DataSize),
+		CodeOrigin: header.CodeOrigin,
+		TotalSize:  int(header.TotalSize),
+		StackTop:   header.StackTop,
+		StackSize:  int(header.StackSize),
+	}}
+
+	if version == binaryVersionLayout {
+		program.Code = data[len(data)-r.Len():]
+	} else {
+		var bankHeader binaryBankHeader
+		if err := binary.Read(r, binary.LittleEndian, &bankHeader); err != nil {
+			return Program{}, errors.New("binary header is truncated")
+		}
+		program.Layout.BankWindow = bankHeader.BankWindow
+		program.Layout.BankSize = int(bankHeader.BankSize)
+
+		if program.Code, err = readSection(r, int(bankHeader.CodeSize)); err != nil {
+			return Program{}, errors.New("binary code is truncated")
+		}
+		for i := range int(bankHeader.BankCount) {
+			var size uint16
+			if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+				return Program{}, fmt.Errorf("binary bank %d is truncated", i)
+			}
+			bank, err := readSection(r, int(size))
+			if err != nil {
+				return Program{}, fmt.Errorf("binary bank %d is truncated", i)
+			}
+			program.Banks = append(program.Banks, bank)
+		}
+	}
+
+	if err := program.Layout.Validate(); err != nil {
+		return Program{}, fmt.Errorf("binary has an invalid layout: %w", err)
+	}
+	return checkCodeSize(program)
+}
+
+// checkCodeSize refuses a program whose code doesn't fit its layout
+func checkCodeSize(program Program) (Program, error) {
+	if space := program.Layout.CodeSpace(); len(program.Code) > space {
+		return Program{}, fmt.Errorf("binary has %d bytes of code, more than the %d bytes of code space", len(program.Code), space)
+	}
+	return program, nil
+}
+
+// readSection reads the next size bytes of a binary
+func readSection(r *bytes.Reader, size int) ([]uint8, error) {
+	section := make([]uint8, size)
+	if _, err := io.ReadFull(r, section); err != nil {
+		return nil, err
+	}
+	return section, nil
+}
@@ -0,0 +1,89 @@
+package cpu
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBinaryHeader(t *testing.T) {
+	layout := Layout{DataSize: 8, CodeOrigin: 0x80, TotalSize: 0x800, StackTop: 0x7FF, StackSize: 32}
+	code := []uint8{uint8(OP_LOAD_RV), 0, 1, uint8(OP_HLT_NONE)}
+
+	program, err := DecodeBinary(EncodeBinary(Program{Layout: layout, Code: code}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if program.Layout != layout {
+		t.Errorf("Expected layout %+v, got %+v", layout, program.Layout)
+	}
+
+	if !slices.Equal(program.Code, code) {
+		t.Errorf("Expected code %v, got %v", code, program.Code)
+	}
+
+	// Bare code from before the header uses the default layout
+	program, err = DecodeBinary(code)
+	if err != nil || program.Layout != DefaultLayout || !slices.Equal(program.Code, code) {
+		t.Errorf("Expected bare code with the default layout, got %+v %v", program, err)
+	}
+
+	// Version 1 headers have the layout and then the code
+	v1 := append([]uint8("CPU\x01"), 8, 0, 0x80, 0, 0, 0x08, 0, 0, 0xFF, 0x07, 32, 0)
+	program, err = DecodeBinary(append(v1, code...))
+	if err != nil || program.Layout != layout || !slices.Equal(program.Code, code) {
+		t.Errorf("Expected a version 1 binary to decode, got %+v %v", program, err)
+	}
+
+	if _, err := DecodeBinary(binaryMagic); err == nil {
+		t.Errorf("Expected a truncated header to fail")
+	}
+
+	if _, err := DecodeBinary([]uint8("CPU\x09")); err == nil {
+		t.Errorf("Expected an unknown version to fail")
+	}
+
+	if _, err := DecodeBinary(make([]uint8, DefaultLayout.CodeSpace()+1)); err == nil {
+		t.Errorf("Expected bare code larger than the code space to fail")
+	}
+
+	if _, err := DecodeBinary(EncodeBinary(Program{Layout: layout, Code: make([]uint8, layout.CodeSpace()+1)})); err == nil {
+		t.Errorf("Expected code larger than the layout's code space to fail")
+	}
+
+	layout.StackSize = 0
+	if _, err := DecodeBinary(EncodeBinary(Program{Layout: layout, Code: code})); err == nil {
+		t.Errorf("Expected an invalid layout to fail")
+	}
+}
+
+func TestBinaryBanks(t *testing.T) {
+	layout := DefaultLayout
+	layout.BankWindow, layout.BankSize = 0x8000, 0x100
+	banks := [][]uint8{{1, 2, 3}, {}, {4}}
+
+	data := EncodeBinary(Program{Layout: layout, Code: []uint8{uint8(OP_HLT_NONE)}, Banks: banks})
+	program, err := DecodeBinary(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if program.Layout != layout {
+		t.Errorf("Expected layout %+v, got %+v", layout, program.Layout)
+	}
+	if !slices.Equal(program.Code, []uint8{uint8(OP_HLT_NONE)}) {
+		t.Errorf("Expected the code before the banks, got %v", program.Code)
+	}
+	if len(program.Banks) != len(banks) {
+		t.Fatalf("Expected %d banks, got %d", len(banks), len(program.Banks))
+	}
+	for i, bank := range banks {
+		if !slices.Equal(program.Banks[i], bank) {
+			t.Errorf("Expected bank %d to be %v, got %v", i, bank, program.Banks[i])
+		}
+	}
+
+	if _, err := DecodeBinary(data[:len(data)-1]); err == nil {
+		t.Errorf("Expected a truncated bank to fail")
+	}
+}
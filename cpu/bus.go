@@ -0,0 +1,186 @@
+package cpu
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Bus is what the CPU reads and writes memory through. *Memory is a Bus on its
+// own, and an AddressDecoder builds one out of RAM, ROM and devices.
+type Bus interface {
+	Read(address uint16) (uint8, error)
+	Write(address uint16, value uint8) error
+}
+
+// Device is anything that can be mapped onto an AddressDecoder. Reads and
+// writes are given the offset from the start of the device's range, so a
+// device does not need to know where it is mapped.
+type Device interface {
+	Read(offset uint16) (uint8, error)
+	Write(offset uint16, value uint8) error
+}
+
+// Fetcher is implemented by buses and devices that return something different
+// for instruction fetches than for data reads, such as a bank window. The CPU
+// fetches instructions through it when it can.
+type Fetcher interface {
+	Fetch(address uint16) (uint8, error)
+}
+
+type mapping struct {
+	start  uint16
+	end    int // exclusive
+	device Device
+}
+
+// AddressDecoder is a Bus that routes each address to the device mapped over
+// it. Accessing an address with nothing mapped goes to the fallback bus if
+// there is one, and is a bus error otherwise.
+type AddressDecoder struct {
+	mappings []mapping
+	fallback Bus
+}
+
+func NewAddressDecoder() *AddressDecoder {
+	return &AddressDecoder{}
+}
+
+// Map places a device over size bytes starting at start. Ranges may not run
+// past the end of the address space or overlap an existing mapping.
+func (d *AddressDecoder) Map(start uint16, size int, device Device) error {
+	end := int(start) + size
+	if size <= 0 || end > TotalMemorySize {
+		return fmt.Errorf("invalid range %#04x+%d", start, size)
+	}
+
+	for _, m := range d.mappings {
+		if int(start) < m.end && end > int(m.start) {
+			return fmt.Errorf("range %#04x+%d overlaps mapping at %#04x", start, size, m.start)
+		}
+	}
+
+	d.mappings = append(d.mappings, mapping{start, end, device})
+	sort.Slice(d.mappings, func(i, j int) bool {
+		return d.mappings[i].start < d.mappings[j].start
+	})
+	return nil
+}
+
+// SetFallback sends accesses to addresses with nothing mapped to bus, with the
+// address unchanged. This lets devices be mapped over a flat Memory.
+func (d *AddressDecoder) SetFallback(bus Bus) {
+	d.fallback = bus
+}
+
+func (d *AddressDecoder) find(address uint16) (mapping, bool) {
+	i := sort.Search(len(d.mappings), func(i int) bool {
+		return d.mappings[i].end > int(address)
+	})
+	if i == len(d.mappings) || d.mappings[i].start > address {
+		return mapping{}, false
+	}
+	return d.mappings[i], true
+}
+
+func (d *AddressDecoder) Read(address uint16) (uint8, error) {
+	m, ok := d.find(address)
+	if !ok && d.fallback != nil {
+		return d.fallback.Read(address)
+	}
+	if !ok {
+		return 0, fmt.Errorf("%w: read at unmapped address %d", BUS_ERROR, address)
+	}
+	return m.device.Read(address - m.start)
+}
+
+func (d *AddressDecoder) Write(address uint16, value uint8) error {
+	m, ok := d.find(address)
+	if !ok && d.fallback != nil {
+		return d.fallback.Write(address, value)
+	}
+	if !ok {
+		return fmt.Errorf("%w: write at unmapped address %d", BUS_ERROR, address)
+	}
+	return m.device.Write(address-m.start, value)
+}
+
+// Fetch fetches from the device mapped over address, or the fallback bus, as a
+// Fetcher if it is one and as a read otherwise
+func (d *AddressDecoder) Fetch(address uint16) (uint8, error) {
+	m, ok := d.find(address)
+	if !ok && d.fallback != nil {
+		if fetcher, ok := d.fallback.(Fetcher); ok {
+			return fetcher.Fetch(address)
+		}
+		return d.fallback.Read(address)
+	}
+	if !ok {
+		return 0, fmt.Errorf("%w: fetch at unmapped address %d", BUS_ERROR, address)
+	}
+	if fetcher, ok := m.device.(Fetcher); ok {
+		return fetcher.Fetch(address - m.start)
+	}
+	return m.device.Read(address - m.start)
+}
+
+// CheckExecute passes the check on to the device mapped over address, or the
+// fallback bus, if it is an ExecuteChecker
+func (d *AddressDecoder) CheckExecute(address uint16) error {
+	m, ok := d.find(address)
+	if !ok {
+		if checker, ok := d.fallback.(ExecuteChecker); ok {
+			return checker.CheckExecute(address)
+		}
+		return nil
+	}
+	if checker, ok := m.device.(ExecuteChecker); ok {
+		return checker.CheckExecute(address - m.start)
+	}
+	return nil
+}
+
+// RAM is a block of read/write memory for mapping onto an AddressDecoder
+type RAM struct {
+	Data []uint8
+}
+
+func NewRAM(size int) *RAM {
+	return &RAM{Data: make([]uint8, size)}
+}
+
+func (r *RAM) Read(offset uint16) (uint8, error) {
+	if int(offset) >= len(r.Data) {
+		return 0, fmt.Errorf("%w: read at offset %d", MEMORY_OUT_OF_BOUNDS, offset)
+	}
+	return r.Data[offset], nil
+}
+
+func (r *RAM) Write(offset uint16, value uint8) error {
+	if int(offset) >= len(r.Data) {
+		return fmt.Errorf("%w: write at offset %d", MEMORY_OUT_OF_BOUNDS, offset)
+	}
+	r.Data[offset] = value
+	return nil
+}
+
+// ROM is read-only memory initialised from a byte slice. Writes to it are bus
+// errors.
+type ROM struct {
+	data []uint8
+}
+
+func NewROM(data []uint8) *ROM {
+	return &ROM{data: slices.Clone(data)}
+}
+
+func (r *ROM) Read(offset uint16) (uint8, error) {
+	if int(offset) >= len(r.data) {
+		return 0, fmt.Errorf("%w: read at offset %d", MEMORY_OUT_OF_BOUNDS, offset)
+	}
+	return r.data[offset], nil
+}
+
+func (r *ROM) Write(offset uint16, value uint8) error {
+	return fmt.Errorf("%w: write to read-only memory at offset %d", BUS_ERROR, offset)
+}
@@ -0,0 +1,194 @@
+package cpu
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// latch is a test device that remembers the last write at each offset and
+// counts its reads
+type latch struct {
+	writes map[uint16]uint8
+	reads  int
+}
+
+func (l *latch) Read(offset uint16) (uint8, error) {
+	l.reads++
+	return uint8(offset) + 100, nil
+}
+
+func (l *latch) Write(offset uint16, value uint8) error {
+	l.writes[offset] = value
+	return nil
+}
+
+func TestAddressDecoder(t *testing.T) {
+	decoder := NewAddressDecoder()
+	ram := NewRAM(0x100)
+	rom := NewROM([]uint8{1, 2, 3})
+
+	if err := decoder.Map(0x1000, 0x100, ram); err != nil {
+		t.Fatalf("Unexpected error mapping RAM: %s", err)
+	}
+	if err := decoder.Map(0x0000, 3, rom); err != nil {
+		t.Fatalf("Unexpected error mapping ROM: %s", err)
+	}
+
+	if err := decoder.Write(0x1010, 42); err != nil {
+		t.Errorf("Unexpected error writing RAM: %s", err)
+	}
+	if ram.Data[0x10] != 42 {
+		t.Errorf("Expected the write at offset 0x10 of RAM, got %d", ram.Data[0x10])
+	}
+
+	if value, err := decoder.Read(0x0002); err != nil || value != 3 {
+		t.Errorf("Expected to read 3 from ROM, got %d (%v)", value, err)
+	}
+
+	if err := decoder.Write(0x0001, 9); !errors.Is(err, BUS_ERROR) {
+		t.Errorf("Expected a bus error writing ROM, got %v", err)
+	}
+
+	for _, address := range []uint16{0x0003, 0x0FFF, 0x1100, 0xFFFF} {
+		if _, err := decoder.Read(address); !errors.Is(err, BUS_ERROR) {
+			t.Errorf("Expected a bus error reading unmapped address %#04x, got %v", address, err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		start uint16
+		size  int
+	}{
+		{"overlapping start", 0x10FF, 0x10},
+		{"overlapping end", 0x0F00, 0x101},
+		{"covering", 0x0000, 0x2000},
+		{"past end of memory", 0xFFFF, 2},
+		{"empty", 0x2000, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := decoder.Map(tt.start, tt.size, NewRAM(tt.size)); err == nil {
+				t.Errorf("Expected mapping %#04x+%d to fail", tt.start, tt.size)
+			}
+		})
+	}
+}
+
+func TestCPUOnAddressDecoder(t *testing.T) {
+	code, err := NewAssembler([]string{
+		"STORE 0x8002 65",
+		"LOADM R0 0x8005",
+		"PUSH R0",
+		"POP R1",
+		"HLT",
+	}, DefaultLayout).Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	device := &latch{writes: make(map[uint16]uint8)}
+	decoder := NewAddressDecoder()
+	decoder.Map(0, CodeMemoryStart, NewRAM(CodeMemoryStart))
+	decoder.Map(CodeMemoryStart, len(code), NewROM(code))
+	decoder.Map(0x8000, 0x10, device)
+	decoder.Map(DefaultStackLimit, StackSize, NewRAM(StackSize))
+
+	cpu := NewCPU(DefaultLayout)
+	if err := cpu.Execute(decoder); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if device.writes[2] != 65 {
+		t.Errorf("Expected the device to see 65 at offset 2, got %v", device.writes)
+	}
+
+	if cpu.Registers[1] != 105 {
+		t.Errorf("Expected to read 105 from the device, got %d", cpu.Registers[1])
+	}
+}
+
+func TestStoreDoesNotReadDevice(t *testing.T) {
+	code, err := NewAssembler([]string{
+		"STORE 0x8000 5",
+		"STORE 0x8001 6",
+		"HLT",
+	}, DefaultLayout).Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	device := &latch{writes: make(map[uint16]uint8)}
+	decoder := NewAddressDecoder()
+	decoder.SetFallback(NewRAM(TotalMemorySize))
+	decoder.Map(0x8000, 0x10, device)
+	decoder.Map(CodeMemoryStart, len(code), NewROM(code))
+
+	cpu := NewCPU(DefaultLayout)
+	if err := cpu.Execute(decoder); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if device.reads != 0 || len(device.writes) != 2 {
+		t.Errorf("Expected 2 writes and no reads, got %d writes and %d reads", len(device.writes), device.reads)
+	}
+
+	// A write watchpoint needs the old value
+	cpu.AddWatchpoint(0x8001, WATCH_WRITE)
+	cpu.Reset()
+	stop, err := cpu.Run(decoder, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error running: %s", err)
+	}
+	if stop.Kind != STOP_WATCHPOINT || stop.Watch.OldValue != 101 {
+		t.Errorf("Expected the watchpoint to see the old value 101, got %+v", stop)
+	}
+	if device.reads != 1 {
+		t.Errorf("Expected only the watched address to be read, got %d reads", device.reads)
+	}
+}
+
+func TestBusErrorAtRuntime(t *testing.T) {
+	code, err := NewAssembler([]string{
+		"LOADM R0 0x4000",
+		"HLT",
+	}, DefaultLayout).Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	decoder := NewAddressDecoder()
+	decoder.Map(CodeMemoryStart, len(code), NewROM(code))
+
+	err = NewCPU(DefaultLayout).Execute(decoder)
+
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Type != BUS_ERROR {
+		t.Fatalf("Expected a bus runtime error, got %v", err)
+	}
+
+	if !slices.Equal(runtimeErr.Operands, []uint8{0, 0x00, 0x40}) {
+		t.Errorf("Expected the faulting operands, got %v", runtimeErr.Operands)
+	}
+}
+
+func TestAddressDecoderFallback(t *testing.T) {
+	mem := NewMemory(DefaultLayout)
+	ram := NewRAM(0x10)
+
+	decoder := NewAddressDecoder()
+	decoder.SetFallback(mem)
+	decoder.Map(0x2000, 0x10, ram)
+
+	decoder.Write(0x2001, 1)
+	decoder.Write(0x3001, 2)
+
+	if ram.Data[1] != 1 || mem.Data[0x2001] != 0 {
+		t.Errorf("Expected the mapped device to take the write over the fallback")
+	}
+
+	if mem.Data[0x3001] != 2 {
+		t.Errorf("Expected the fallback to get the unmapped write at its own address")
+	}
+}
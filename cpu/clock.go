@@ -0,0 +1,22 @@
+package cpu
+
+// CyclesPerInstruction is how much emulated time each instruction takes. Time
+// is counted in instructions rather than wall time so runs are reproducible.
+const CyclesPerInstruction = 1
+
+// Ticker is anything that advances with emulated time, such as a timer device
+type Ticker interface {
+	Tick(cycles int)
+}
+
+// AddTicker registers t to be ticked after every instruction
+func (c *CPU) AddTicker(t Ticker) {
+	c.tickers = append(c.tickers, t)
+}
+
+func (c *CPU) tick(cycles int) {
+	c.Cycles += uint64(cycles)
+	for _, t := range c.tickers {
+		t.Tick(cycles)
+	}
+}
@@ -0,0 +1,42 @@
+package cpu
+
+import "testing"
+
+type countingTicker struct {
+	cycles int
+}
+
+func (t *countingTicker) Tick(cycles int) {
+	t.cycles += cycles
+}
+
+func TestCycles(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R0 1",
+		"INC R0",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	ticker := &countingTicker{}
+	cpu.AddTicker(ticker)
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.Cycles != 3*CyclesPerInstruction {
+		t.Errorf("Expected %d cycles, got %d", 3*CyclesPerInstruction, cpu.Cycles)
+	}
+
+	if ticker.cycles != 3*CyclesPerInstruction {
+		t.Errorf("Expected the ticker to see %d cycles, got %d", 3*CyclesPerInstruction, ticker.cycles)
+	}
+
+	cpu.Reset()
+	if cpu.Cycles != 0 {
+		t.Errorf("Expected Reset to clear the cycle counter, got %d", cpu.Cycles)
+	}
+}
@@ -1,6 +1,14 @@
 package cpu
 
-import "fmt"
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync/atomic"
+)
 
 const RegisterCount = 4
 
@@ -20,9 +28,13 @@ var RegisterMap = map[string]uint8{
 }
 
 type Flags struct {
-	Equal   uint8
-	Greater uint8
-	Less    uint8
+	Equal    uint8
+	Greater  uint8
+	Less     uint8
+	Zero     uint8
+	Carry    uint8
+	Overflow uint8
+	Sign     uint8
 }
 
 func (f *Flags) Compare(a, b uint8) {
@@ -45,349 +57,1436 @@ func (f *Flags) Compare(a, b uint8) {
 	}
 }
 
+// CompareSigned compares a and b as two's complement signed bytes. Flipping
+// the sign bit maps signed order onto unsigned order.
+func (f *Flags) CompareSigned(a, b uint8) {
+	f.Compare(a^0x80, b^0x80)
+}
+
+// add returns a + b + carry, setting the arithmetic flags. Carry is set when
+// the unsigned result wraps and Overflow when the signed result does.
+func (f *Flags) add(a, b, carry uint8) uint8 {
+	sum := uint16(a) + uint16(b) + uint16(carry)
+	result := uint8(sum)
+	f.Carry = boolFlag(sum > 0xFF)
+	f.Overflow = boolFlag((a^result)&(b^result)&0x80 != 0)
+	f.setZeroSign(result)
+	return result
+}
+
+// sub returns a - b - borrow, setting the arithmetic flags. Carry is set when
+// the subtraction borrows.
+func (f *Flags) sub(a, b, borrow uint8) uint8 {
+	difference := int(a) - int(b) - int(borrow)
+	result := uint8(difference)
+	f.Carry = boolFlag(difference < 0)
+	f.Overflow = boolFlag((a^b)&(a^result)&0x80 != 0)
+	f.setZeroSign(result)
+	return result
+}
+
+// mul returns a * b, setting Carry and Overflow when the product needs more
+// than 8 bits
+func (f *Flags) mul(a, b uint8) uint8 {
+	product := uint16(a) * uint16(b)
+	result := uint8(product)
+	f.Carry = boolFlag(product > 0xFF)
+	f.Overflow = f.Carry
+	f.setZeroSign(result)
+	return result
+}
+
+// shl shifts a left one bit, moving the bit shifted out into Carry
+func (f *Flags) shl(a uint8) uint8 {
+	result := a << 1
+	f.Carry = a >> 7
+	f.Overflow = boolFlag((a^result)&0x80 != 0)
+	f.setZeroSign(result)
+	return result
+}
+
+// shr shifts a right one bit, moving the bit shifted out into Carry
+func (f *Flags) shr(a uint8) uint8 {
+	result := a >> 1
+	f.Carry = a & 1
+	f.Overflow = a >> 7
+	f.setZeroSign(result)
+	return result
+}
+
+// logic sets the flags for a result that cannot carry or overflow
+func (f *Flags) logic(result uint8) uint8 {
+	f.Carry = 0
+	f.Overflow = 0
+	f.setZeroSign(result)
+	return result
+}
+
+func (f *Flags) setZeroSign(result uint8) {
+	f.Zero = boolFlag(result == 0)
+	f.Sign = result >> 7
+}
+
+// signedDiv divides a by b as signed bytes, truncating toward zero. The one
+// result that does not fit, -128 / -1, wraps back to -128 and sets Overflow.
+func (f *Flags) signedDiv(a, b uint8) uint8 {
+	result := f.logic(uint8(int8(a) / int8(b)))
+	f.Overflow = boolFlag(int8(a) == -128 && int8(b) == -1)
+	return result
+}
+
+// signedMod returns the remainder of a / b as signed bytes, which takes the
+// sign of a
+func (f *Flags) signedMod(a, b uint8) uint8 {
+	return f.logic(uint8(int8(a) % int8(b)))
+}
+
+// sar shifts a right one bit keeping its sign, moving the bit shifted out into
+// Carry
+func (f *Flags) sar(a uint8) uint8 {
+	result := uint8(int8(a) >> 1)
+	f.Carry = a & 1
+	f.Overflow = 0
+	f.setZeroSign(result)
+	return result
+}
+
+// SignExtend widens a signed byte to 16 bits
+func SignExtend(value uint8) uint16 {
+	return uint16(int16(int8(value)))
+}
+
+func boolFlag(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 type CPU struct {
 	Registers      [RegisterCount]uint8
-	Stack          *Stack
 	Flags          Flags
 	ProgramCounter uint16
+	StackPointer   uint16
+	StackLimit     uint16
+	Halted         bool
+	layout         Layout
+
+	// Interrupt state, see interrupt.go
+	VectorBase        uint16
+	InterruptsEnabled bool
+	InterruptMask     uint8
+	pendingIRQs       atomic.Uint32
+
+	// UserMode is set while running user code, see privilege.go
+	UserMode   bool
+	stackTop   uint16
+	otherStack bankedStack
+
+	ports [PortCount]portMapping
+
+	// Emulated time, see clock.go
+	Cycles  uint64
+	tickers []Ticker
+
+	snapshotters []Snapshotter
+
+	breakpoints map[int]Breakpoint
+	watchpoints map[int]Watchpoint
+	nextDebugID int
+	watchHit    *WatchHit
+	// resumeAddress is the breakpoint Run last stopped on, which is skipped
+	// once so that calling Run again makes progress
+	resumeAddress *uint16
+
+	// fetched holds the bytes of the current instruction fetched so far
+	fetched []uint8
+
+	input       *bufio.Reader
+	inputSource *inputReader
+	output      io.Writer
+	// unreadLine is a line READS read but couldn't store, which it reads
+	// again in place of the next line of input
+	unreadLine []byte
+
+	// limits and outputBytes are only in effect during ExecuteContext
+	limits      Limits
+	outputBytes int
+}
+
+type StopKind uint8
+
+const (
+	STOP_HALT       StopKind = iota // The program executed HLT
+	STOP_STEP_LIMIT                 // The step budget given to Run was used up
+	STOP_BREAKPOINT                 // The program counter reached a breakpoint
+	STOP_WATCHPOINT                 // An instruction accessed a watched address
+)
+
+// StopReason reports why Run returned and how many instructions it executed.
+// Breakpoint is set for STOP_BREAKPOINT and Watch for STOP_WATCHPOINT.
+type StopReason struct {
+	Kind       StopKind
+	Steps      int
+	Breakpoint Breakpoint
+	Watch      WatchHit
 }
 
-func NewCPU() *CPU {
+// NewCPU creates a CPU for memory divided up by layout
+func NewCPU(layout Layout) *CPU {
 	cpu := &CPU{
 		Registers:      [RegisterCount]uint8{},
-		Stack:          NewStack(),
 		ProgramCounter: 0,
+		StackPointer:   layout.StackTop,
+		StackLimit:     layout.StackLimit(),
+		stackTop:       layout.StackTop,
+		otherStack:     noStack,
+		VectorBase:     layout.VectorBase(),
+		layout:         layout,
+		output:         os.Stdout,
 	}
+	cpu.SetInput(os.Stdin)
 
 	return cpu
 }
 
-func (c *CPU) Execute(memory *Memory) {
-	c.ProgramCounter = CodeMemoryStart
+// Reset clears the registers and flags, empties the stack and points the
+// program counter at the start of code memory. Memory is left untouched.
+func (c *CPU) Reset() {
+	c.setMode(nil, false)
+	c.Registers = [RegisterCount]uint8{}
+	c.StackPointer = c.layout.StackTop
+	c.otherStack = noStack
+	c.Flags = Flags{}
+	c.ProgramCounter = c.layout.CodeOrigin
+	c.Halted = false
+	c.InterruptsEnabled = false
+	c.InterruptMask = 0
+	c.pendingIRQs.Store(0)
+	c.Cycles = 0
+	c.watchHit = nil
+	c.resumeAddress = nil
+}
+
+// Execute runs the program in memory from the start of code memory until it
+// halts or reaches a breakpoint or watchpoint. A fault in the program is
+// returned as a *RuntimeError.
+func (c *CPU) Execute(bus Bus) error {
+	c.ProgramCounter = c.layout.CodeOrigin
+	c.Halted = false
+
+	_, err := c.Run(bus, 0)
+	return err
+}
+
+// Run executes from the current program counter until the program halts,
+// maxSteps instructions have run, or a breakpoint or watchpoint is hit. A
+// maxSteps of zero or less means no limit. Calling Run again after it stops
+// resumes where it left off.
+func (c *CPU) Run(bus Bus, maxSteps int) (StopReason, error) {
+	steps := 0
+	for !c.Halted {
+		if maxSteps > 0 && steps >= maxSteps {
+			return StopReason{Kind: STOP_STEP_LIMIT, Steps: steps}, nil
+		}
 
-	for {
-		halted := c.executeNext(memory)
-		if halted {
-			return
+		// Enter any pending interrupt first so breakpoints in handlers are hit
+		if err := c.serviceInterrupts(bus); err != nil {
+			return StopReason{Steps: steps}, err
+		}
+
+		resuming := c.resumeAddress != nil && *c.resumeAddress == c.ProgramCounter
+		c.resumeAddress = nil
+		if bp, ok := c.breakpointAt(c.ProgramCounter); ok && !resuming {
+			address := c.ProgramCounter
+			c.resumeAddress = &address
+			return StopReason{Kind: STOP_BREAKPOINT, Steps: steps, Breakpoint: bp}, nil
+		}
+
+		if _, err := c.Step(bus); err != nil {
+			return StopReason{Steps: steps}, err
+		}
+		steps++
+
+		if c.watchHit != nil {
+			return StopReason{Kind: STOP_WATCHPOINT, Steps: steps, Watch: *c.watchHit}, nil
 		}
 	}
+
+	return StopReason{Kind: STOP_HALT, Steps: steps}, nil
 }
 
-func (c *CPU) executeNext(memory *Memory) (halted bool) {
-	halted = false
+// Step executes the single instruction at the program counter and returns it.
+// A pending interrupt is entered first, so the instruction may be the first
+// one of its handler. Afterwards every ticker is advanced by the cycles the
+// instruction took.
+func (c *CPU) Step(bus Bus) (Instruction, error) {
+	if c.Halted {
+		return Instruction{}, ErrHalted
+	}
+
+	c.watchHit = nil
+	c.setMode(bus, c.UserMode)
+	if err := c.serviceInterrupts(bus); err != nil {
+		return Instruction{}, err
+	}
 
-	opcode := memory.Read(c.ProgramCounter)
-	c.ProgramCounter++
+	start := c.ProgramCounter
+	halted, err := c.executeNext(bus)
+	// Describe the instruction from the bytes it ran with rather than fetching
+	// them again, which could have side effects
+	instruction := fetchedInstruction(start, c.fetched)
+	if err != nil {
+		return instruction, err
+	}
+	c.Halted = halted
+	c.tick(CyclesPerInstruction)
+
+	return instruction, nil
+}
+
+// executeNext runs the instruction at the program counter, wrapping any fault
+// into a *RuntimeError that points at the faulting instruction. Faults with a
+// trap vector trap to their handler instead, if there is one.
+func (c *CPU) executeNext(bus Bus) (halted bool, err error) {
+	start := c.ProgramCounter
+	registers, flags, stackPointer := c.Registers, c.Flags, c.StackPointer
+	c.fetched = c.fetched[:0]
+
+	opcode, err := c.fetch(bus)
+	if err == nil {
+		err = c.checkPrivilege(Opcode(opcode))
+	}
+	if err == nil {
+		halted, err = c.execute(bus, Opcode(opcode))
+	}
+	if err == nil {
+		return halted, nil
+	}
+
+	var errType RuntimeErrorType
+	errors.As(err, &errType)
+	if vector, ok := faultVectors[errType]; ok {
+		// Undo the instruction so the handler can return to run it again
+		end, faultRegisters, faultFlags, faultStackPointer := c.ProgramCounter, c.Registers, c.Flags, c.StackPointer
+		c.Registers, c.Flags, c.StackPointer, c.ProgramCounter = registers, flags, stackPointer, start
 
-	switch Opcode(opcode) {
+		trapped, trapErr := c.trap(bus, vector)
+		if trapped && trapErr == nil {
+			return false, nil
+		}
+		if trapped {
+			err = fmt.Errorf("%w while handling %v", trapErr, err)
+		} else {
+			// Report the fault as it happened
+			c.Registers, c.Flags, c.StackPointer, c.ProgramCounter = faultRegisters, faultFlags, faultStackPointer, end
+		}
+	}
+
+	// Everything fetched after the opcode belongs to the faulting instruction
+	var operands []uint8
+	if len(c.fetched) > 1 {
+		operands = c.fetched[1:]
+	}
+	return false, c.newRuntimeError(start, Opcode(opcode), operands, err)
+}
+
+func (c *CPU) execute(bus Bus, opcode Opcode) (halted bool, err error) {
+	switch opcode {
 	case OP_LOAD_RV:
-		reg, value := c.prepRVInstruction(memory)
+		reg, value, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		c.Registers[reg] = value
 
 	case OP_LOAD_RR:
-		reg1, reg2 := c.prepRRInstruction(memory)
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		c.Registers[reg1] = c.Registers[reg2]
 
 	case OP_LOADM_RA:
-		reg, address := c.prepRAInstruction(memory)
-		c.Registers[reg] = memory.ReadStoredMemory(uint16(address))
+		reg, address, err := c.prepRAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		value, err := c.readMemory(bus, address)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = value
 
 	case OP_STORE_RA:
-		reg, address := c.prepRAInstruction(memory)
-		memory.WriteStoredMemory(uint16(address), c.Registers[reg])
+		reg, address, err := c.prepRAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if err := c.writeMemory(bus, address, c.Registers[reg]); err != nil {
+			return false, err
+		}
 
 	case OP_STORE_AV:
-		address, value := c.prepAVInstruction(memory)
-		memory.WriteStoredMemory(uint16(address), value)
+		address, value, err := c.prepAVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if err := c.writeMemory(bus, address, value); err != nil {
+			return false, err
+		}
 
 	case OP_STORE_RR:
-		reg1, reg2 := c.prepRRInstruction(memory)
-		memory.WriteStoredMemory(uint16(c.Registers[reg1]), c.Registers[reg2])
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if err := c.writeMemory(bus, uint16(c.Registers[reg1]), c.Registers[reg2]); err != nil {
+			return false, err
+		}
 
 	case OP_ADD_RR:
-		reg1, reg2 := c.prepRRInstruction(memory)
-		c.Registers[reg1] += c.Registers[reg2]
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg1] = c.Flags.add(c.Registers[reg1], c.Registers[reg2], 0)
 
 	case OP_ADD_RV:
-		reg, value := c.prepRVInstruction(memory)
-		c.Registers[reg] += value
+		reg, value, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = c.Flags.add(c.Registers[reg], value, 0)
 
 	case OP_SUB_RR:
-		reg1, reg2 := c.prepRRInstruction(memory)
-		c.Registers[reg1] -= c.Registers[reg2]
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg1] = c.Flags.sub(c.Registers[reg1], c.Registers[reg2], 0)
 
 	case OP_SUB_RV:
-		reg, value := c.prepRVInstruction(memory)
-		c.Registers[reg] -= value
+		reg, value, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = c.Flags.sub(c.Registers[reg], value, 0)
 
 	case OP_MUL_RR:
-		reg1, reg2 := c.prepRRInstruction(memory)
-		c.Registers[reg1] *= c.Registers[reg2]
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg1] = c.Flags.mul(c.Registers[reg1], c.Registers[reg2])
 
 	case OP_MUL_RV:
-		reg, value := c.prepRVInstruction(memory)
-		c.Registers[reg] *= value
+		reg, value, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = c.Flags.mul(c.Registers[reg], value)
 
 	case OP_DIV_RR:
-		reg1, reg2 := c.prepRRInstruction(memory)
-		c.Registers[reg1] /= c.Registers[reg2]
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Registers[reg2] == 0 {
+			return false, DIVIDE_BY_ZERO
+		}
+		c.Registers[reg1] = c.Flags.logic(c.Registers[reg1] / c.Registers[reg2])
 
 	case OP_DIV_RV:
-		reg, value := c.prepRVInstruction(memory)
-		c.Registers[reg] /= value
+		reg, value, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if value == 0 {
+			return false, DIVIDE_BY_ZERO
+		}
+		c.Registers[reg] = c.Flags.logic(c.Registers[reg] / value)
 
 	case OP_MOD_RR:
-		reg1, reg2 := c.prepRRInstruction(memory)
-		c.Registers[reg1] %= c.Registers[reg2]
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Registers[reg2] == 0 {
+			return false, DIVIDE_BY_ZERO
+		}
+		c.Registers[reg1] = c.Flags.logic(c.Registers[reg1] % c.Registers[reg2])
 
 	case OP_MOD_RV:
-		reg, value := c.prepRVInstruction(memory)
-		c.Registers[reg] %= value
+		reg, value, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if value == 0 {
+			return false, DIVIDE_BY_ZERO
+		}
+		c.Registers[reg] = c.Flags.logic(c.Registers[reg] % value)
 
 	case OP_AND_RR:
-		reg1, reg2 := c.prepRRInstruction(memory)
-		c.Registers[reg1] &= c.Registers[reg2]
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg1] = c.Flags.logic(c.Registers[reg1] & c.Registers[reg2])
 
 	case OP_AND_RV:
-		reg, value := c.prepRVInstruction(memory)
-		c.Registers[reg] &= value
+		reg, value, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = c.Flags.logic(c.Registers[reg] & value)
 
 	case OP_OR_RR:
-		reg1, reg2 := c.prepRRInstruction(memory)
-		c.Registers[reg1] |= c.Registers[reg2]
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg1] = c.Flags.logic(c.Registers[reg1] | c.Registers[reg2])
 
 	case OP_OR_RV:
-		reg, value := c.prepRVInstruction(memory)
-		c.Registers[reg] |= value
+		reg, value, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = c.Flags.logic(c.Registers[reg] | value)
 
 	case OP_XOR_RR:
-		reg1, reg2 := c.prepRRInstruction(memory)
-		c.Registers[reg1] ^= c.Registers[reg2]
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg1] = c.Flags.logic(c.Registers[reg1] ^ c.Registers[reg2])
 
 	case OP_XOR_RV:
-		reg, value := c.prepRVInstruction(memory)
-		c.Registers[reg] ^= value
+		reg, value, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = c.Flags.logic(c.Registers[reg] ^ value)
 
 	case OP_NOT_R:
-		reg := c.prepRInstruction(memory)
-		c.Registers[reg] = ^c.Registers[reg]
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = c.Flags.logic(^c.Registers[reg])
 
 	case OP_SHL_R:
-		reg := c.prepRInstruction(memory)
-		c.Registers[reg] <<= 1
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = c.Flags.shl(c.Registers[reg])
 
 	case OP_SHR_R:
-		reg := c.prepRInstruction(memory)
-		c.Registers[reg] >>= 1
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = c.Flags.shr(c.Registers[reg])
 
 	case OP_INC_R:
-		reg := c.prepRInstruction(memory)
-		c.Registers[reg]++
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = c.Flags.add(c.Registers[reg], 1, 0)
 
 	case OP_DEC_R:
-		reg := c.prepRInstruction(memory)
-		c.Registers[reg]--
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = c.Flags.sub(c.Registers[reg], 1, 0)
 
 	case OP_PUSH_R:
-		reg := c.prepRInstruction(memory)
-		c.Stack.Push(c.Registers[reg])
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if err := c.push(bus, c.Registers[reg]); err != nil {
+			return false, err
+		}
 
 	case OP_PUSH_V:
-		value := c.prepVInstruction(memory)
-		c.Stack.Push(value)
+		value, err := c.prepVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if err := c.push(bus, value); err != nil {
+			return false, err
+		}
 
 	case OP_POP_NONE:
 		c.prepNoneInstruction()
-		c.Stack.Pop()
+		if _, err := c.pop(bus); err != nil {
+			return false, err
+		}
 
 	case OP_POP_R:
-		reg := c.prepRInstruction(memory)
-		c.Registers[reg] = c.Stack.Pop()
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		value, err := c.pop(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = value
 
 	case OP_CMP_RR:
-		reg1, reg2 := c.prepRRInstruction(memory)
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		c.Flags.Compare(c.Registers[reg1], c.Registers[reg2])
+		c.Flags.sub(c.Registers[reg1], c.Registers[reg2], 0)
 
 	case OP_CMP_RV:
-		reg, value := c.prepRVInstruction(memory)
+		reg, value, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		c.Flags.Compare(c.Registers[reg], value)
+		c.Flags.sub(c.Registers[reg], value, 0)
 
 	case OP_JMP_A:
-		address := c.prepAInstruction(memory)
-		c.ProgramCounter = uint16(address)
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.ProgramCounter = address
 
 	case OP_JMP_R:
-		reg := c.prepRInstruction(memory)
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		c.ProgramCounter = uint16(c.Registers[reg])
 
 	case OP_JE_A:
-		address := c.prepAInstruction(memory)
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		if c.Flags.Equal == 1 {
-			c.ProgramCounter = uint16(address)
+			c.ProgramCounter = address
 		}
 
 	case OP_JE_R:
-		reg := c.prepRInstruction(memory)
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		if c.Flags.Equal == 1 {
 			c.ProgramCounter = uint16(c.Registers[reg])
 		}
 
 	case OP_JNE_A:
-		address := c.prepAInstruction(memory)
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		if c.Flags.Equal == 0 {
-			c.ProgramCounter = uint16(address)
+			c.ProgramCounter = address
 		}
 
 	case OP_JNE_R:
-		reg := c.prepRInstruction(memory)
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		if c.Flags.Equal == 0 {
 			c.ProgramCounter = uint16(c.Registers[reg])
 		}
 
 	case OP_JG_A:
-		address := c.prepAInstruction(memory)
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		if c.Flags.Greater == 1 {
-			c.ProgramCounter = uint16(address)
+			c.ProgramCounter = address
 		}
 
 	case OP_JG_R:
-		reg := c.prepRInstruction(memory)
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		if c.Flags.Greater == 1 {
 			c.ProgramCounter = uint16(c.Registers[reg])
 		}
 
 	case OP_JGE_A:
-		address := c.prepAInstruction(memory)
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		if c.Flags.Greater == 1 || c.Flags.Equal == 1 {
-			c.ProgramCounter = uint16(address)
+			c.ProgramCounter = address
 		}
 
 	case OP_JGE_R:
-		reg := c.prepRInstruction(memory)
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		if c.Flags.Greater == 1 || c.Flags.Equal == 1 {
 			c.ProgramCounter = uint16(c.Registers[reg])
 		}
 
 	case OP_JL_A:
-		address := c.prepAInstruction(memory)
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		if c.Flags.Less == 1 {
-			c.ProgramCounter = uint16(address)
+			c.ProgramCounter = address
 		}
 
 	case OP_JL_R:
-		reg := c.prepRInstruction(memory)
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		if c.Flags.Less == 1 {
 			c.ProgramCounter = uint16(c.Registers[reg])
 		}
 
 	case OP_JLE_A:
-		address := c.prepAInstruction(memory)
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		if c.Flags.Less == 1 || c.Flags.Equal == 1 {
-			c.ProgramCounter = uint16(address)
+			c.ProgramCounter = address
 		}
 
 	case OP_JLE_R:
-		reg := c.prepRInstruction(memory)
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		if c.Flags.Less == 1 || c.Flags.Equal == 1 {
 			c.ProgramCounter = uint16(c.Registers[reg])
 		}
 
 	case OP_CALL_A:
-		address := c.prepAInstruction(memory)
-		c.Stack.Push(uint8(c.ProgramCounter))
-		c.ProgramCounter = uint16(address)
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if err := c.pushAddress(bus, c.ProgramCounter); err != nil {
+			return false, err
+		}
+		c.ProgramCounter = address
 
 	case OP_CALL_R:
-		reg := c.prepRInstruction(memory)
-		c.Stack.Push(uint8(c.ProgramCounter))
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if err := c.pushAddress(bus, c.ProgramCounter); err != nil {
+			return false, err
+		}
 		c.ProgramCounter = uint16(c.Registers[reg])
 
 	case OP_RET_NONE:
 		c.prepNoneInstruction()
-		c.ProgramCounter = uint16(c.Stack.Pop())
+		address, err := c.popAddress(bus)
+		if err != nil {
+			return false, err
+		}
+		c.ProgramCounter = address
 
 	case OP_PRINT_V:
-		value := c.prepVInstruction(memory)
-		fmt.Println(value)
+		value, err := c.prepVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if err := c.print(fmt.Sprintln(value)); err != nil {
+			return false, err
+		}
 
 	case OP_PRINT_R:
-		reg := c.prepRInstruction(memory)
-		fmt.Println(c.Registers[reg])
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if err := c.print(fmt.Sprintln(c.Registers[reg])); err != nil {
+			return false, err
+		}
 
 	case OP_PRINTS_A:
-		address := c.prepAInstruction(memory)
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
 		// Build the string up from memory. The string is null-terminated.
 		var str []byte
 		for {
-			value := memory.ReadStoredMemory(uint16(address))
+			value, err := c.readMemory(bus, address)
+			if err != nil {
+				return false, err
+			}
 			if value == 0 {
 				break
 			}
 			str = append(str, value)
 			address++
 		}
-		fmt.Print(string(str))
+		if err := c.print(string(str)); err != nil {
+			return false, err
+		}
 
 	case OP_HLT_NONE:
 		halted = true
 
+	case OP_READ_R:
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		value, err := c.readByte()
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = value
+
+	case OP_READS_A:
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		line, err := c.readLine()
+		if err != nil {
+			return false, err
+		}
+		if err := checkWritable(bus, address, len(line)+1); err != nil {
+			// Keep the line for when the instruction runs again
+			c.unreadLine = line
+			return false, err
+		}
+		// Store the line null-terminated, the same way PRINTS expects it
+		for i, value := range append(line, 0) {
+			if err := c.writeMemory(bus, address+uint16(i), value); err != nil {
+				return false, err
+			}
+		}
+
+	case OP_PRINTC_R:
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if err := c.print(string([]byte{c.Registers[reg]})); err != nil {
+			return false, err
+		}
+
+	case OP_PRINTC_V:
+		value, err := c.prepVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if err := c.print(string([]byte{value})); err != nil {
+			return false, err
+		}
+
+	case OP_JZ_A:
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Zero == 1 {
+			c.ProgramCounter = address
+		}
+
+	case OP_JZ_R:
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Zero == 1 {
+			c.ProgramCounter = uint16(c.Registers[reg])
+		}
+
+	case OP_JNZ_A:
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Zero == 0 {
+			c.ProgramCounter = address
+		}
+
+	case OP_JNZ_R:
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Zero == 0 {
+			c.ProgramCounter = uint16(c.Registers[reg])
+		}
+
+	case OP_JC_A:
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Carry == 1 {
+			c.ProgramCounter = address
+		}
+
+	case OP_JC_R:
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Carry == 1 {
+			c.ProgramCounter = uint16(c.Registers[reg])
+		}
+
+	case OP_JNC_A:
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Carry == 0 {
+			c.ProgramCounter = address
+		}
+
+	case OP_JNC_R:
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Carry == 0 {
+			c.ProgramCounter = uint16(c.Registers[reg])
+		}
+
+	case OP_JO_A:
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Overflow == 1 {
+			c.ProgramCounter = address
+		}
+
+	case OP_JO_R:
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Overflow == 1 {
+			c.ProgramCounter = uint16(c.Registers[reg])
+		}
+
+	case OP_JS_A:
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Sign == 1 {
+			c.ProgramCounter = address
+		}
+
+	case OP_JS_R:
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Sign == 1 {
+			c.ProgramCounter = uint16(c.Registers[reg])
+		}
+
+	case OP_ADC_RR:
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg1] = c.Flags.add(c.Registers[reg1], c.Registers[reg2], c.Flags.Carry)
+
+	case OP_ADC_RV:
+		reg, value, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = c.Flags.add(c.Registers[reg], value, c.Flags.Carry)
+
+	case OP_SBB_RR:
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg1] = c.Flags.sub(c.Registers[reg1], c.Registers[reg2], c.Flags.Carry)
+
+	case OP_SBB_RV:
+		reg, value, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = c.Flags.sub(c.Registers[reg], value, c.Flags.Carry)
+
+	case OP_SCMP_RR:
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Flags.CompareSigned(c.Registers[reg1], c.Registers[reg2])
+		c.Flags.sub(c.Registers[reg1], c.Registers[reg2], 0)
+
+	case OP_SCMP_RV:
+		reg, value, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Flags.CompareSigned(c.Registers[reg], value)
+		c.Flags.sub(c.Registers[reg], value, 0)
+
+	case OP_JSG_A:
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Zero == 0 && c.Flags.Sign == c.Flags.Overflow {
+			c.ProgramCounter = address
+		}
+
+	case OP_JSG_R:
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Zero == 0 && c.Flags.Sign == c.Flags.Overflow {
+			c.ProgramCounter = uint16(c.Registers[reg])
+		}
+
+	case OP_JSGE_A:
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Sign == c.Flags.Overflow {
+			c.ProgramCounter = address
+		}
+
+	case OP_JSGE_R:
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Sign == c.Flags.Overflow {
+			c.ProgramCounter = uint16(c.Registers[reg])
+		}
+
+	case OP_JSL_A:
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Sign != c.Flags.Overflow {
+			c.ProgramCounter = address
+		}
+
+	case OP_JSL_R:
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Sign != c.Flags.Overflow {
+			c.ProgramCounter = uint16(c.Registers[reg])
+		}
+
+	case OP_JSLE_A:
+		address, err := c.prepAInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Zero == 1 || c.Flags.Sign != c.Flags.Overflow {
+			c.ProgramCounter = address
+		}
+
+	case OP_JSLE_R:
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Flags.Zero == 1 || c.Flags.Sign != c.Flags.Overflow {
+			c.ProgramCounter = uint16(c.Registers[reg])
+		}
+
+	case OP_IDIV_RR:
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Registers[reg2] == 0 {
+			return false, DIVIDE_BY_ZERO
+		}
+		c.Registers[reg1] = c.Flags.signedDiv(c.Registers[reg1], c.Registers[reg2])
+
+	case OP_IDIV_RV:
+		reg, value, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if value == 0 {
+			return false, DIVIDE_BY_ZERO
+		}
+		c.Registers[reg] = c.Flags.signedDiv(c.Registers[reg], value)
+
+	case OP_IMOD_RR:
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.Registers[reg2] == 0 {
+			return false, DIVIDE_BY_ZERO
+		}
+		c.Registers[reg1] = c.Flags.signedMod(c.Registers[reg1], c.Registers[reg2])
+
+	case OP_IMOD_RV:
+		reg, value, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if value == 0 {
+			return false, DIVIDE_BY_ZERO
+		}
+		c.Registers[reg] = c.Flags.signedMod(c.Registers[reg], value)
+
+	case OP_SAR_R:
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = c.Flags.sar(c.Registers[reg])
+
+	case OP_SEXT_RR:
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg1] = uint8(SignExtend(c.Registers[reg2]) >> 8)
+
+	case OP_LOADSP_RV:
+		reg, offset, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		address, err := c.stackAddress(offset)
+		if err != nil {
+			return false, err
+		}
+		value, err := c.readMemory(bus, address)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = value
+
+	case OP_STORESP_RV:
+		reg, offset, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		address, err := c.stackAddress(offset)
+		if err != nil {
+			return false, err
+		}
+		if err := c.writeMemory(bus, address, c.Registers[reg]); err != nil {
+			return false, err
+		}
+
+	case OP_ADDSP_V:
+		value, err := c.prepVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if c.StackDepth() < int(value) {
+			return false, STACK_UNDERFLOW
+		}
+		c.StackPointer += uint16(value)
+
+	case OP_SUBSP_V:
+		value, err := c.prepVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if err := c.reserveStack(int(value)); err != nil {
+			return false, err
+		}
+		c.StackPointer -= uint16(value)
+
+	case OP_EI_NONE:
+		c.prepNoneInstruction()
+		c.InterruptsEnabled = true
+
+	case OP_DI_NONE:
+		c.prepNoneInstruction()
+		c.InterruptsEnabled = false
+
+	case OP_IRET_NONE:
+		c.prepNoneInstruction()
+		if err := c.returnFromInterrupt(bus); err != nil {
+			return false, err
+		}
+
+	case OP_IN_RV:
+		reg, port, err := c.prepRVInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		value, err := c.in(port)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg] = value
+
+	case OP_OUT_VR:
+		port, reg, err := c.prepVRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		if err := c.out(port, c.Registers[reg]); err != nil {
+			return false, err
+		}
+
+	case OP_SNAP_NONE:
+		c.prepNoneInstruction()
+		if err := c.snapshot(); err != nil {
+			return false, err
+		}
+
+	case OP_SYSCALL_NONE:
+		c.prepNoneInstruction()
+		if err := c.syscall(bus); err != nil {
+			return false, err
+		}
+
+	case OP_SYSRET_NONE:
+		c.prepNoneInstruction()
+		if err := c.sysret(bus); err != nil {
+			return false, err
+		}
+
+	case OP_JMPP_R:
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		address, err := c.pairAddress(reg)
+		if err != nil {
+			return false, err
+		}
+		c.ProgramCounter = address
+
+	case OP_CALLP_R:
+		reg, err := c.prepRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		address, err := c.pairAddress(reg)
+		if err != nil {
+			return false, err
+		}
+		if err := c.pushAddress(bus, c.ProgramCounter); err != nil {
+			return false, err
+		}
+		c.ProgramCounter = address
+
+	case OP_LOADP_RR:
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		address, err := c.pairAddress(reg2)
+		if err != nil {
+			return false, err
+		}
+		value, err := c.readMemory(bus, address)
+		if err != nil {
+			return false, err
+		}
+		c.Registers[reg1] = value
+
+	case OP_STOREP_RR:
+		reg1, reg2, err := c.prepRRInstruction(bus)
+		if err != nil {
+			return false, err
+		}
+		address, err := c.pairAddress(reg1)
+		if err != nil {
+			return false, err
+		}
+		if err := c.writeMemory(bus, address, c.Registers[reg2]); err != nil {
+			return false, err
+		}
+
 	default:
-		panic("Unknown opcode")
+		return false, UNKNOWN_OPCODE
 	}
 
-	return halted
+	return halted, nil
 }
 
-func (c *CPU) prepRRInstruction(memory *Memory) (reg1, reg2 uint8) {
-	reg1 = memory.Read(c.ProgramCounter)
-	c.ProgramCounter++
-	reg2 = memory.Read(c.ProgramCounter)
-	c.ProgramCounter++
-	return reg1, reg2
+func (c *CPU) newRuntimeError(start uint16, opcode Opcode, operands []uint8, err error) *RuntimeError {
+	var errType RuntimeErrorType
+	errors.As(err, &errType)
+
+	runtimeErr := &RuntimeError{
+		Type:           errType,
+		ProgramCounter: start,
+		Opcode:         opcode,
+		Operands:       slices.Clone(operands),
+		Registers:      c.Registers,
+		Flags:          c.Flags,
+		StackPointer:   c.StackPointer,
+		Err:            err,
+	}
+
+	// Leave the program counter on the faulting instruction
+	c.ProgramCounter = start
+
+	return runtimeErr
 }
 
-func (c *CPU) prepRVInstruction(memory *Memory) (reg uint8, value uint8) {
-	reg = memory.Read(c.ProgramCounter)
-	c.ProgramCounter++
-	value = memory.Read(c.ProgramCounter)
-	c.ProgramCounter++
-	return reg, value
+func (c *CPU) prepRRInstruction(bus Bus) (reg1, reg2 uint8, err error) {
+	reg1, err = c.fetchRegister(bus)
+	if err != nil {
+		return 0, 0, err
+	}
+	reg2, err = c.fetchRegister(bus)
+	if err != nil {
+		return 0, 0, err
+	}
+	return reg1, reg2, nil
 }
 
-func (c *CPU) prepRAInstruction(memory *Memory) (reg, address uint8) {
-	reg = memory.Read(c.ProgramCounter)
-	c.ProgramCounter++
-	address = memory.Read(c.ProgramCounter)
-	c.ProgramCounter++
-	return reg, address
+func (c *CPU) prepRVInstruction(bus Bus) (reg uint8, value uint8, err error) {
+	reg, err = c.fetchRegister(bus)
+	if err != nil {
+		return 0, 0, err
+	}
+	value, err = c.fetch(bus)
+	if err != nil {
+		return 0, 0, err
+	}
+	return reg, value, nil
 }
 
-func (c *CPU) prepRInstruction(memory *Memory) (reg uint8) {
-	reg = memory.Read(c.ProgramCounter)
-	c.ProgramCounter++
-	return reg
+func (c *CPU) prepVRInstruction(bus Bus) (value uint8, reg uint8, err error) {
+	value, err = c.fetch(bus)
+	if err != nil {
+		return 0, 0, err
+	}
+	reg, err = c.fetchRegister(bus)
+	if err != nil {
+		return 0, 0, err
+	}
+	return value, reg, nil
 }
 
-func (c *CPU) prepAVInstruction(memory *Memory) (address, value uint8) {
-	address = memory.Read(c.ProgramCounter)
-	c.ProgramCounter++
-	value = memory.Read(c.ProgramCounter)
-	c.ProgramCounter++
-	return address, value
+func (c *CPU) prepRAInstruction(bus Bus) (reg uint8, address uint16, err error) {
+	reg, err = c.fetchRegister(bus)
+	if err != nil {
+		return 0, 0, err
+	}
+	address, err = c.fetchAddress(bus)
+	if err != nil {
+		return 0, 0, err
+	}
+	return reg, address, nil
 }
 
-func (c *CPU) prepAInstruction(memory *Memory) (address uint8) {
-	address = memory.Read(c.ProgramCounter)
-	c.ProgramCounter++
-	return address
+func (c *CPU) prepRInstruction(bus Bus) (reg uint8, err error) {
+	return c.fetchRegister(bus)
 }
 
-func (c *CPU) prepVInstruction(memory *Memory) (value uint8) {
-	value = memory.Read(c.ProgramCounter)
-	c.ProgramCounter++
-	return value
+func (c *CPU) prepAVInstruction(bus Bus) (address uint16, value uint8, err error) {
+	address, err = c.fetchAddress(bus)
+	if err != nil {
+		return 0, 0, err
+	}
+	value, err = c.fetch(bus)
+	if err != nil {
+		return 0, 0, err
+	}
+	return address, value, nil
+}
+
+func (c *CPU) prepAInstruction(bus Bus) (address uint16, err error) {
+	return c.fetchAddress(bus)
+}
+
+func (c *CPU) prepVInstruction(bus Bus) (value uint8, err error) {
+	return c.fetch(bus)
 }
 
 func (c *CPU) prepNoneInstruction() {
 	// c.ProgramCounter++
 }
+
+// fetch reads the byte at the program counter and advances past it
+func (c *CPU) fetch(bus Bus) (uint8, error) {
+	if checker, ok := bus.(ExecuteChecker); ok {
+		if err := checker.CheckExecute(c.ProgramCounter); err != nil {
+			return 0, err
+		}
+	}
+	value, err := readCode(bus, c.ProgramCounter)
+	if err != nil {
+		return 0, err
+	}
+	c.fetched = append(c.fetched, value)
+	c.ProgramCounter++
+	return value, nil
+}
+
+// readCode reads an instruction byte, through the bus's Fetcher if it has one
+func readCode(bus Bus, address uint16) (uint8, error) {
+	if fetcher, ok := bus.(Fetcher); ok {
+		return fetcher.Fetch(address)
+	}
+	return bus.Read(address)
+}
+
+// fetchAddress reads a two byte little-endian address operand
+func (c *CPU) fetchAddress(bus Bus) (uint16, error) {
+	low, err := c.fetch(bus)
+	if err != nil {
+		return 0, err
+	}
+	high, err := c.fetch(bus)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(high)<<8 | uint16(low), nil
+}
+
+// fetchRegister reads a register operand, rejecting indexes with no register
+func (c *CPU) fetchRegister(bus Bus) (uint8, error) {
+	reg, err := c.fetch(bus)
+	if err != nil {
+		return 0, err
+	}
+	if reg >= RegisterCount {
+		return 0, fmt.Errorf("%w: %d", INVALID_REGISTER_INDEX, reg)
+	}
+	return reg, nil
+}
+
+// pairAddress returns the address in the register pair starting at reg, which
+// holds the high byte while the next register holds the low byte
+func (c *CPU) pairAddress(reg uint8) (uint16, error) {
+	if reg+1 >= RegisterCount {
+		return 0, fmt.Errorf("%w: register pair %d", INVALID_REGISTER_INDEX, reg)
+	}
+	return uint16(c.Registers[reg])<<8 | uint16(c.Registers[reg+1]), nil
+}
@@ -1,11 +1,13 @@
 package cpu
 
 import (
+	"errors"
+	"slices"
 	"testing"
 )
 
 func TestNewCPU(t *testing.T) {
-	cpu := NewCPU()
+	cpu := NewCPU(DefaultLayout)
 
 	for i, reg := range cpu.Registers {
 		if reg != 0 {
@@ -19,8 +21,8 @@ func TestNewCPU(t *testing.T) {
 }
 
 func TestCPUExecution(t *testing.T) {
-	cpu := NewCPU()
-	mem := NewMemory()
+	cpu := NewCPU(DefaultLayout)
+	mem := NewMemory(DefaultLayout)
 
 	mem.Write(CodeMemoryStart+0, uint8(OP_LOAD_RV))
 	mem.Write(CodeMemoryStart+1, 0)
@@ -112,8 +114,8 @@ func TestStoreRA(t *testing.T) {
 
 	cpu.Execute(mem)
 
-	if mem.Read(0) != 42 {
-		t.Errorf("Expected memory address 0 to be 42, got %d", mem.Read(0))
+	if mem.Data[0] != 42 {
+		t.Errorf("Expected memory address 0 to be 42, got %d", mem.Data[0])
 	}
 }
 
@@ -128,8 +130,8 @@ func TestStoreAV(t *testing.T) {
 
 	cpu.Execute(mem)
 
-	if mem.Read(0) != 42 {
-		t.Errorf("Expected memory address 0 to be 42, got %d", mem.Read(0))
+	if mem.Data[0] != 42 {
+		t.Errorf("Expected memory address 0 to be 42, got %d", mem.Data[0])
 	}
 }
 
@@ -146,8 +148,8 @@ func TestStoreRR(t *testing.T) {
 
 	cpu.Execute(mem)
 
-	if mem.Read(0) != 42 {
-		t.Errorf("Expected memory address 0 to be 42, got %d", mem.Read(0))
+	if mem.Data[0] != 42 {
+		t.Errorf("Expected memory address 0 to be 42, got %d", mem.Data[0])
 	}
 }
 
@@ -528,12 +530,12 @@ func TestPushR(t *testing.T) {
 
 	cpu.Execute(mem)
 
-	if len(cpu.Stack.Data) != 1 {
-		t.Errorf("Expected stack to have 1 item, got %d", len(cpu.Stack.Data))
+	if cpu.StackDepth() != 1 {
+		t.Errorf("Expected stack to have 1 item, got %d", cpu.StackDepth())
 	}
 
-	if cpu.Stack.Pop() != 42 {
-		t.Errorf("Expected stack to pop 42, got %d", cpu.Stack.Pop())
+	if value, _ := cpu.pop(mem); value != 42 {
+		t.Errorf("Expected stack to pop 42, got %d", value)
 	}
 }
 
@@ -548,12 +550,12 @@ func TestPushV(t *testing.T) {
 
 	cpu.Execute(mem)
 
-	if len(cpu.Stack.Data) != 1 {
-		t.Errorf("Expected stack to have 1 item, got %d", len(cpu.Stack.Data))
+	if cpu.StackDepth() != 1 {
+		t.Errorf("Expected stack to have 1 item, got %d", cpu.StackDepth())
 	}
 
-	if cpu.Stack.Pop() != 42 {
-		t.Errorf("Expected stack to pop 42, got %d", cpu.Stack.Pop())
+	if value, _ := cpu.pop(mem); value != 42 {
+		t.Errorf("Expected stack to pop 42, got %d", value)
 	}
 }
 
@@ -569,8 +571,8 @@ func TestPopNone(t *testing.T) {
 
 	cpu.Execute(mem)
 
-	if len(cpu.Stack.Data) != 0 {
-		t.Errorf("Expected stack to have 0 items, got %d", len(cpu.Stack.Data))
+	if cpu.StackDepth() != 0 {
+		t.Errorf("Expected stack to have 0 items, got %d", cpu.StackDepth())
 	}
 }
 
@@ -1157,11 +1159,11 @@ func TestCallA(t *testing.T) {
 		t.Errorf("Expected program counter to be 5, got %d", cpu.ProgramCounter)
 	}
 
-	if len(cpu.Stack.Data) != 1 {
-		t.Errorf("Expected stack to have 1 item, got %d", len(cpu.Stack.Data))
+	if cpu.StackDepth() != 2 {
+		t.Errorf("Expected stack to have 2 items, got %d", cpu.StackDepth())
 	}
 
-	if cpu.Stack.Pop() != CodeMemoryStart+2 {
+	if address, _ := cpu.popAddress(mem); address != CodeMemoryStart+3 {
 		t.Errorf("Expected return address to be pushed to stack")
 	}
 }
@@ -1183,18 +1185,18 @@ func TestCallR(t *testing.T) {
 		t.Errorf("Expected program counter to be 5, got %d", cpu.ProgramCounter)
 	}
 
-	if len(cpu.Stack.Data) != 1 {
-		t.Errorf("Expected stack to have 1 item, got %d", len(cpu.Stack.Data))
+	if cpu.StackDepth() != 2 {
+		t.Errorf("Expected stack to have 2 items, got %d", cpu.StackDepth())
 	}
 
-	if cpu.Stack.Pop() != CodeMemoryStart+5 {
+	if address, _ := cpu.popAddress(mem); address != CodeMemoryStart+5 {
 		t.Errorf("Expected return address to be pushed to stack")
 	}
 }
 
 func TestRet(t *testing.T) {
 	cpu, mem, err := prepCpuAndMem([]string{
-		"CALL 57",
+		"CALL 58",
 		"RET",
 	})
 	if err != nil {
@@ -1205,7 +1207,619 @@ func TestRet(t *testing.T) {
 	cpu.executeNext(mem)
 	cpu.executeNext(mem)
 
-	if cpu.ProgramCounter != CodeMemoryStart+2 {
-		t.Errorf("Expected program counter to be 2, got %d", cpu.ProgramCounter)
+	if cpu.ProgramCounter != CodeMemoryStart+3 {
+		t.Errorf("Expected program counter to be %d, got %d", CodeMemoryStart+3, cpu.ProgramCounter)
+	}
+}
+
+func TestStorePRR(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R0 0x90",
+		"LOAD R1 0x10",
+		"LOAD R2 42",
+		"STOREP R0 R2",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.Execute(mem)
+
+	if mem.Data[0x9010] != 42 {
+		t.Errorf("Expected memory address 0x9010 to be 42, got %d", mem.Data[0x9010])
+	}
+}
+
+func TestLoadPRR(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R1 0x90",
+		"LOAD R2 0x10",
+		"LOADP R0 R1",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+	mem.Data[0x9010] = 42
+
+	cpu.Execute(mem)
+
+	if cpu.Registers[0] != 42 {
+		t.Errorf("Expected register 0 to be 42, got %d", cpu.Registers[0])
+	}
+}
+
+func TestJmpPR(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R2 0x12",
+		"LOAD R3 0x34",
+		"JMPP R2",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.ProgramCounter = CodeMemoryStart
+	cpu.executeNext(mem)
+	cpu.executeNext(mem)
+	cpu.executeNext(mem)
+
+	if cpu.ProgramCounter != 0x1234 {
+		t.Errorf("Expected program counter to be 0x1234, got %#x", cpu.ProgramCounter)
+	}
+}
+
+func TestCallPR(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R0 0x12",
+		"LOAD R1 0x34",
+		"CALLP R0",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.ProgramCounter = CodeMemoryStart
+	cpu.executeNext(mem)
+	cpu.executeNext(mem)
+	cpu.executeNext(mem)
+
+	if cpu.ProgramCounter != 0x1234 {
+		t.Errorf("Expected program counter to be 0x1234, got %#x", cpu.ProgramCounter)
+	}
+
+	if address, _ := cpu.popAddress(mem); address != CodeMemoryStart+8 {
+		t.Errorf("Expected return address to be pushed to stack")
+	}
+}
+
+func TestRegisterPairOutOfRange(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"JMPP R3",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	if err := cpu.Execute(mem); !errors.Is(err, INVALID_REGISTER_INDEX) {
+		t.Errorf("Expected a pair starting at the last register to be invalid, got %v", err)
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []string
+		errType RuntimeErrorType
+	}{
+		{"divide by zero", []string{"LOAD R0 1", "DIV R0 R1", "HLT"}, DIVIDE_BY_ZERO},
+		{"modulo by zero", []string{"LOAD R0 1", "MOD R0 0", "HLT"}, DIVIDE_BY_ZERO},
+		{"stack underflow", []string{"POP R0", "HLT"}, STACK_UNDERFLOW},
+		{"stack overflow", []string{"loop:", "PUSH 1", "JMP loop"}, STACK_OVERFLOW},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu, mem, err := prepCpuAndMem(tt.program)
+			if err != nil {
+				t.Fatalf("Error preparing CPU and memory: %s", err)
+			}
+
+			err = cpu.Execute(mem)
+			if !errors.Is(err, tt.errType) {
+				t.Errorf("Expected %q error, got %v", tt.errType, err)
+			}
+		})
+	}
+}
+
+func TestRuntimeErrorDetails(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R0 7",
+		"DIV R0 R1",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	err = cpu.Execute(mem)
+
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("Expected a RuntimeError, got %v", err)
+	}
+
+	if runtimeErr.ProgramCounter != CodeMemoryStart+3 {
+		t.Errorf("Expected fault at %d, got %d", CodeMemoryStart+3, runtimeErr.ProgramCounter)
+	}
+
+	if runtimeErr.Opcode != OP_DIV_RR {
+		t.Errorf("Expected opcode %d, got %d", OP_DIV_RR, runtimeErr.Opcode)
+	}
+
+	if !slices.Equal(runtimeErr.Operands, []uint8{R0, R1}) {
+		t.Errorf("Expected operands [0 1], got %v", runtimeErr.Operands)
+	}
+
+	if runtimeErr.Registers[0] != 7 {
+		t.Errorf("Expected register 0 snapshot to be 7, got %d", runtimeErr.Registers[0])
+	}
+
+	if cpu.ProgramCounter != CodeMemoryStart+3 {
+		t.Errorf("Expected program counter to stay on the fault, got %d", cpu.ProgramCounter)
+	}
+}
+
+func TestRuntimeErrorUnknownOpcode(t *testing.T) {
+	cpu := NewCPU(DefaultLayout)
+	mem := NewMemory(DefaultLayout)
+
+	mem.Write(CodeMemoryStart, 0xFF)
+
+	err := cpu.Execute(mem)
+	if !errors.Is(err, UNKNOWN_OPCODE) {
+		t.Errorf("Expected unknown opcode error, got %v", err)
+	}
+}
+
+func TestRuntimeErrorInvalidRegister(t *testing.T) {
+	cpu := NewCPU(DefaultLayout)
+	mem := NewMemory(DefaultLayout)
+
+	mem.Write(CodeMemoryStart+0, uint8(OP_INC_R))
+	mem.Write(CodeMemoryStart+1, RegisterCount)
+
+	err := cpu.Execute(mem)
+	if !errors.Is(err, INVALID_REGISTER_INDEX) {
+		t.Errorf("Expected invalid register error, got %v", err)
+	}
+}
+
+func TestStep(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R0 42",
+		"INC R0",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.Reset()
+
+	instruction, err := cpu.Step(mem)
+	if err != nil {
+		t.Fatalf("Unexpected error stepping: %s", err)
+	}
+
+	if instruction.Opcode != OP_LOAD_RV || instruction.Address != CodeMemoryStart {
+		t.Errorf("Expected LOAD at %d, got %d at %d", CodeMemoryStart, instruction.Opcode, instruction.Address)
+	}
+
+	if instruction.String() != "LOAD R0 42" {
+		t.Errorf("Expected instruction to print as LOAD R0 42, got %s", instruction)
+	}
+
+	if cpu.Registers[0] != 42 {
+		t.Errorf("Expected register 0 to be 42, got %d", cpu.Registers[0])
+	}
+
+	cpu.Step(mem)
+	cpu.Step(mem)
+
+	if !cpu.Halted {
+		t.Errorf("Expected CPU to be halted")
+	}
+
+	if _, err := cpu.Step(mem); !errors.Is(err, ErrHalted) {
+		t.Errorf("Expected stepping a halted CPU to fail, got %v", err)
+	}
+}
+
+// countingBus counts the reads of each address made through it
+type countingBus struct {
+	Bus
+	reads map[uint16]int
+}
+
+func (b *countingBus) Read(address uint16) (uint8, error) {
+	b.reads[address]++
+	return b.Bus.Read(address)
+}
+
+func TestStepReadsInstructionOnce(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R0 42",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+	bus := &countingBus{Bus: mem, reads: make(map[uint16]int)}
+
+	cpu.Reset()
+	instruction, err := cpu.Step(bus)
+	if err != nil {
+		t.Fatalf("Unexpected error stepping: %s", err)
+	}
+
+	if instruction.String() != "LOAD R0 42" {
+		t.Errorf("Expected LOAD R0 42, got %s", instruction)
+	}
+	for address := CodeMemoryStart; address < CodeMemoryStart+3; address++ {
+		if bus.reads[uint16(address)] != 1 {
+			t.Errorf("Expected address %d to be read once, got %d reads", address, bus.reads[uint16(address)])
+		}
+	}
+}
+
+func TestRunMaxSteps(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"loop:",
+		"INC R0",
+		"CMP R0 10",
+		"JNE loop",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.Reset()
+
+	stop, err := cpu.Run(mem, 6)
+	if err != nil {
+		t.Fatalf("Unexpected error running: %s", err)
+	}
+
+	if stop.Kind != STOP_STEP_LIMIT || stop.Steps != 6 {
+		t.Errorf("Expected to stop on the step limit after 6 steps, got %+v", stop)
+	}
+
+	if cpu.Registers[0] != 2 {
+		t.Errorf("Expected register 0 to be 2, got %d", cpu.Registers[0])
+	}
+
+	// Resuming continues from the current program counter
+	stop, err = cpu.Run(mem, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error running: %s", err)
+	}
+
+	if stop.Kind != STOP_HALT {
+		t.Errorf("Expected to stop on halt, got %+v", stop)
+	}
+
+	if cpu.Registers[0] != 10 {
+		t.Errorf("Expected register 0 to be 10, got %d", cpu.Registers[0])
+	}
+}
+
+func TestReset(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R0 42",
+		"PUSH R0",
+		"CMP R0 42",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.Execute(mem)
+	cpu.Reset()
+
+	if cpu.Registers[0] != 0 {
+		t.Errorf("Expected register 0 to be 0, got %d", cpu.Registers[0])
+	}
+
+	if cpu.StackDepth() != 0 {
+		t.Errorf("Expected stack to be empty, got %d items", cpu.StackDepth())
+	}
+
+	if cpu.Flags != (Flags{}) {
+		t.Errorf("Expected flags to be cleared, got %+v", cpu.Flags)
+	}
+
+	if cpu.Halted || cpu.ProgramCounter != CodeMemoryStart {
+		t.Errorf("Expected CPU to be ready to run from %d", CodeMemoryStart)
+	}
+}
+
+func TestArithmeticFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []string
+		result  uint8
+		flags   Flags
+	}{
+		{"add carry", []string{"LOAD R0 200", "ADD R0 100"}, 44, Flags{Carry: 1}},
+		{"add overflow", []string{"LOAD R0 100", "ADD R0 100"}, 200, Flags{Overflow: 1, Sign: 1}},
+		{"add zero", []string{"LOAD R0 255", "ADD R0 1"}, 0, Flags{Zero: 1, Carry: 1}},
+		{"sub borrow", []string{"LOAD R0 1", "SUB R0 2"}, 255, Flags{Carry: 1, Sign: 1}},
+		{"sub overflow", []string{"LOAD R0 128", "SUB R0 1"}, 127, Flags{Overflow: 1}},
+		{"inc wrap", []string{"LOAD R0 255", "INC R0"}, 0, Flags{Zero: 1, Carry: 1}},
+		{"dec to zero", []string{"LOAD R0 1", "DEC R0"}, 0, Flags{Zero: 1}},
+		{"mul carry", []string{"LOAD R0 16", "MUL R0 16"}, 0, Flags{Zero: 1, Carry: 1, Overflow: 1}},
+		{"shl carry", []string{"LOAD R0 129", "SHL R0"}, 2, Flags{Carry: 1, Overflow: 1}},
+		{"shr carry", []string{"LOAD R0 3", "SHR R0"}, 1, Flags{Carry: 1}},
+		{"and zero", []string{"LOAD R0 240", "AND R0 15"}, 0, Flags{Zero: 1}},
+		{"not sign", []string{"LOAD R0 0", "NOT R0"}, 255, Flags{Sign: 1}},
+		{"adc", []string{"LOAD R0 200", "ADD R0 100", "LOAD R0 1", "ADC R0 1"}, 3, Flags{}},
+		{"sbb", []string{"LOAD R0 1", "SUB R0 2", "LOAD R0 5", "SBB R0 1"}, 3, Flags{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu, mem, err := prepCpuAndMem(append(tt.program, "HLT"))
+			if err != nil {
+				t.Fatalf("Error preparing CPU and memory: %s", err)
+			}
+
+			cpu.Execute(mem)
+
+			if cpu.Registers[0] != tt.result {
+				t.Errorf("Expected register 0 to be %d, got %d", tt.result, cpu.Registers[0])
+			}
+
+			if cpu.Flags != tt.flags {
+				t.Errorf("Expected flags %+v, got %+v", tt.flags, cpu.Flags)
+			}
+		})
+	}
+}
+
+func TestMultiByteAdd(t *testing.T) {
+	// 0x01F0 + 0x0120 = 0x0310, low bytes in R0/R2 and high bytes in R1/R3
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R0 240",
+		"LOAD R1 1",
+		"LOAD R2 32",
+		"LOAD R3 1",
+		"ADD R0 R2",
+		"ADC R1 R3",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.Execute(mem)
+
+	if cpu.Registers[0] != 0x10 || cpu.Registers[1] != 0x03 {
+		t.Errorf("Expected 0x0310, got 0x%02X%02X", cpu.Registers[1], cpu.Registers[0])
+	}
+}
+
+func TestFlagJumps(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  string
+		jump   string
+		jumped bool
+	}{
+		{"jz taken", "SUB R0 0", "JZ", true},
+		{"jz not taken", "ADD R0 1", "JZ", false},
+		{"jnz taken", "ADD R0 1", "JNZ", true},
+		{"jc taken", "SUB R0 1", "JC", true},
+		{"jnc taken", "ADD R0 1", "JNC", true},
+		{"jnc not taken", "SUB R0 1", "JNC", false},
+		{"jo taken", "SUB R0 128", "JO", true},
+		{"jo not taken", "ADD R0 128", "JO", false},
+		{"js taken", "SUB R0 1", "JS", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu, mem, err := prepCpuAndMem([]string{
+				tt.setup,
+				tt.jump + " skip",
+				"LOAD R1 1",
+				"skip:",
+				"HLT",
+			})
+			if err != nil {
+				t.Fatalf("Error preparing CPU and memory: %s", err)
+			}
+
+			cpu.Execute(mem)
+
+			if jumped := cpu.Registers[1] == 0; jumped != tt.jumped {
+				t.Errorf("Expected jumped to be %t, got %t", tt.jumped, jumped)
+			}
+		})
+	}
+}
+
+func TestSignedArithmetic(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []string
+		result  int8
+	}{
+		{"negative literal", []string{"LOAD R0 -5"}, -5},
+		{"idiv", []string{"LOAD R0 -7", "IDIV R0 2"}, -3},
+		{"idiv negative divisor", []string{"LOAD R0 7", "LOAD R1 -2", "IDIV R0 R1"}, -3},
+		{"imod", []string{"LOAD R0 -7", "IMOD R0 2"}, -1},
+		{"imod registers", []string{"LOAD R0 7", "LOAD R1 -2", "IMOD R0 R1"}, 1},
+		{"sar", []string{"LOAD R0 -8", "SAR R0"}, -4},
+		{"sar odd", []string{"LOAD R0 -1", "SAR R0"}, -1},
+		{"sext negative", []string{"LOAD R1 -100", "SEXT R0 R1"}, -1},
+		{"sext positive", []string{"LOAD R1 100", "SEXT R0 R1"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu, mem, err := prepCpuAndMem(append(tt.program, "HLT"))
+			if err != nil {
+				t.Fatalf("Error preparing CPU and memory: %s", err)
+			}
+
+			if err := cpu.Execute(mem); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			if int8(cpu.Registers[0]) != tt.result {
+				t.Errorf("Expected register 0 to be %d, got %d", tt.result, int8(cpu.Registers[0]))
+			}
+		})
+	}
+}
+
+func TestIdivOverflow(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R0 -128",
+		"IDIV R0 -1",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if int8(cpu.Registers[0]) != -128 || cpu.Flags.Overflow != 1 {
+		t.Errorf("Expected -128 with overflow, got %d with overflow %d", int8(cpu.Registers[0]), cpu.Flags.Overflow)
+	}
+}
+
+func TestIdivByZero(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R0 -1",
+		"IMOD R0 R1",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	if err := cpu.Execute(mem); !errors.Is(err, DIVIDE_BY_ZERO) {
+		t.Errorf("Expected divide by zero error, got %v", err)
+	}
+}
+
+func TestScmp(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R0 -1",
+		"SCMP R0 1",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.Execute(mem)
+
+	if cpu.Flags.Less != 1 || cpu.Flags.Greater != 0 || cpu.Flags.Equal != 0 {
+		t.Errorf("Expected -1 to compare less than 1, got %+v", cpu.Flags)
+	}
+}
+
+func TestSignedJumps(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   string
+		jump   string
+		jumped bool
+	}{
+		{"jsl negative", "-1", "1", "JSL", true},
+		{"jsl positive", "1", "-1", "JSL", false},
+		{"jsl overflow", "-128", "1", "JSL", true},
+		{"jsle equal", "-5", "-5", "JSLE", true},
+		{"jsg", "100", "-100", "JSG", true},
+		{"jsg equal", "3", "3", "JSG", false},
+		{"jsge equal", "-3", "-3", "JSGE", true},
+		{"jsge less", "-4", "-3", "JSGE", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu, mem, err := prepCpuAndMem([]string{
+				"LOAD R0 " + tt.a,
+				"CMP R0 " + tt.b,
+				tt.jump + " skip",
+				"LOAD R1 1",
+				"skip:",
+				"HLT",
+			})
+			if err != nil {
+				t.Fatalf("Error preparing CPU and memory: %s", err)
+			}
+
+			cpu.Execute(mem)
+
+			if jumped := cpu.Registers[1] == 0; jumped != tt.jumped {
+				t.Errorf("Expected jumped to be %t, got %t", tt.jumped, jumped)
+			}
+		})
+	}
+}
+
+func TestSignExtend(t *testing.T) {
+	if SignExtend(0xFE) != 0xFFFE {
+		t.Errorf("Expected 0xFE to extend to 0xFFFE, got 0x%04X", SignExtend(0xFE))
+	}
+
+	if SignExtend(0x7F) != 0x007F {
+		t.Errorf("Expected 0x7F to extend to 0x007F, got 0x%04X", SignExtend(0x7F))
+	}
+}
+
+func TestLargeProgram(t *testing.T) {
+	// Pad the program so the function and its caller sit past the first 256 bytes
+	program := []string{"JMP main"}
+	for range 100 {
+		program = append(program, "INC R0")
+	}
+	program = append(program,
+		"function:",
+		"STORE R0 1000",
+		"RET",
+		"main:",
+		"LOAD R0 42",
+		"CALL function",
+		"LOADM R1 1000",
+		"HLT",
+	)
+
+	cpu, mem, err := prepCpuAndMem(program)
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.Registers[1] != 42 {
+		t.Errorf("Expected register 1 to be 42, got %d", cpu.Registers[1])
+	}
+
+	if mem.Data[1000] != 42 {
+		t.Errorf("Expected memory address 1000 to be 42, got %d", mem.Data[1000])
 	}
 }
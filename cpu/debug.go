@@ -0,0 +1,127 @@
+package cpu
+
+type WatchAccess uint8
+
+const (
+	WATCH_READ       WatchAccess = 1 << iota // Stop when an instruction reads the address
+	WATCH_WRITE                              // Stop when an instruction writes the address
+	WATCH_READ_WRITE = WATCH_READ | WATCH_WRITE
+)
+
+// Breakpoint stops execution before the instruction at Address runs
+type Breakpoint struct {
+	ID      int
+	Address uint16
+}
+
+// Watchpoint stops execution after an instruction accesses Address
+type Watchpoint struct {
+	ID      int
+	Address uint16
+	Access  WatchAccess
+}
+
+// WatchHit describes the memory access that triggered a watchpoint. For reads
+// OldValue and NewValue are both the value that was read.
+type WatchHit struct {
+	Watchpoint Watchpoint
+	Access     WatchAccess
+	OldValue   uint8
+	NewValue   uint8
+}
+
+// AddBreakpoint registers a breakpoint at address and returns its id
+func (c *CPU) AddBreakpoint(address uint16) int {
+	c.nextDebugID++
+	if c.breakpoints == nil {
+		c.breakpoints = make(map[int]Breakpoint)
+	}
+	c.breakpoints[c.nextDebugID] = Breakpoint{ID: c.nextDebugID, Address: address}
+	return c.nextDebugID
+}
+
+// RemoveBreakpoint deletes a breakpoint, reporting whether it existed
+func (c *CPU) RemoveBreakpoint(id int) bool {
+	_, ok := c.breakpoints[id]
+	delete(c.breakpoints, id)
+	return ok
+}
+
+// AddWatchpoint registers a watchpoint on address and returns its id
+func (c *CPU) AddWatchpoint(address uint16, access WatchAccess) int {
+	c.nextDebugID++
+	if c.watchpoints == nil {
+		c.watchpoints = make(map[int]Watchpoint)
+	}
+	c.watchpoints[c.nextDebugID] = Watchpoint{ID: c.nextDebugID, Address: address, Access: access}
+	return c.nextDebugID
+}
+
+// RemoveWatchpoint deletes a watchpoint, reporting whether it existed
+func (c *CPU) RemoveWatchpoint(id int) bool {
+	_, ok := c.watchpoints[id]
+	delete(c.watchpoints, id)
+	return ok
+}
+
+func (c *CPU) breakpointAt(address uint16) (Breakpoint, bool) {
+	for _, bp := range c.breakpoints {
+		if bp.Address == address {
+			return bp, true
+		}
+	}
+	return Breakpoint{}, false
+}
+
+// watchpointOn returns the watchpoint an access to address would hit, unless
+// the current instruction already hit one
+func (c *CPU) watchpointOn(address uint16, access WatchAccess) (Watchpoint, bool) {
+	if c.watchHit != nil {
+		return Watchpoint{}, false
+	}
+	for _, wp := range c.watchpoints {
+		if wp.Address == address && wp.Access&access != 0 {
+			return wp, true
+		}
+	}
+	return Watchpoint{}, false
+}
+
+// checkWatchpoints records the first watchpoint hit by the current instruction
+func (c *CPU) checkWatchpoints(address uint16, access WatchAccess, oldValue, newValue uint8) {
+	if wp, ok := c.watchpointOn(address, access); ok {
+		c.watchHit = &WatchHit{
+			Watchpoint: wp,
+			Access:     access,
+			OldValue:   oldValue,
+			NewValue:   newValue,
+		}
+	}
+}
+
+func (c *CPU) readMemory(bus Bus, address uint16) (uint8, error) {
+	value, err := bus.Read(address)
+	if err != nil {
+		return 0, err
+	}
+	c.checkWatchpoints(address, WATCH_READ, value, value)
+	return value, nil
+}
+
+// writeMemory only reads the old value when a watchpoint wants it, since
+// reading a device can have side effects. If the address can't be read, such as
+// write-only memory, the old value is reported as 0.
+func (c *CPU) writeMemory(bus Bus, address uint16, value uint8) error {
+	_, watched := c.watchpointOn(address, WATCH_WRITE)
+	var oldValue uint8
+	if watched {
+		oldValue, _ = bus.Read(address)
+	}
+	if err := bus.Write(address, value); err != nil {
+		return err
+	}
+	if watched {
+		c.checkWatchpoints(address, WATCH_WRITE, oldValue, value)
+	}
+	return nil
+}
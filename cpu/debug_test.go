@@ -0,0 +1,148 @@
+package cpu
+
+import "testing"
+
+func TestBreakpoint(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R0 1",
+		"LOAD R0 2",
+		"LOAD R0 3",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	id := cpu.AddBreakpoint(CodeMemoryStart + 3)
+	cpu.Reset()
+
+	stop, err := cpu.Run(mem, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error running: %s", err)
+	}
+
+	if stop.Kind != STOP_BREAKPOINT || stop.Breakpoint.ID != id {
+		t.Errorf("Expected to stop on breakpoint %d, got %+v", id, stop)
+	}
+
+	if cpu.ProgramCounter != CodeMemoryStart+3 {
+		t.Errorf("Expected program counter to be %d, got %d", CodeMemoryStart+3, cpu.ProgramCounter)
+	}
+
+	if cpu.Registers[0] != 1 {
+		t.Errorf("Expected the breakpoint instruction not to run, register 0 is %d", cpu.Registers[0])
+	}
+
+	// Resuming runs the instruction under the breakpoint
+	stop, err = cpu.Run(mem, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error running: %s", err)
+	}
+
+	if stop.Kind != STOP_HALT {
+		t.Errorf("Expected to stop on halt, got %+v", stop)
+	}
+
+	if cpu.Registers[0] != 3 {
+		t.Errorf("Expected register 0 to be 3, got %d", cpu.Registers[0])
+	}
+
+	if !cpu.RemoveBreakpoint(id) {
+		t.Errorf("Expected breakpoint %d to be removed", id)
+	}
+
+	if err := cpu.Execute(mem); err != nil || !cpu.Halted {
+		t.Errorf("Expected program to run to completion without the breakpoint")
+	}
+}
+
+func TestBreakpointInLoop(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"loop:",
+		"INC R0",
+		"CMP R0 3",
+		"JNE loop",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.AddBreakpoint(CodeMemoryStart)
+	cpu.Reset()
+
+	hits := 0
+	for !cpu.Halted {
+		stop, err := cpu.Run(mem, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error running: %s", err)
+		}
+		if stop.Kind == STOP_BREAKPOINT {
+			hits++
+		}
+	}
+
+	if hits != 3 {
+		t.Errorf("Expected breakpoint to be hit 3 times, got %d", hits)
+	}
+}
+
+func TestWatchpointWrite(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"STORE 5 10",
+		"STORE 5 20",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	id := cpu.AddWatchpoint(5, WATCH_WRITE)
+	cpu.Reset()
+
+	stop, err := cpu.Run(mem, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error running: %s", err)
+	}
+
+	if stop.Kind != STOP_WATCHPOINT || stop.Watch.Watchpoint.ID != id {
+		t.Fatalf("Expected to stop on watchpoint %d, got %+v", id, stop)
+	}
+
+	if stop.Watch.OldValue != 0 || stop.Watch.NewValue != 10 {
+		t.Errorf("Expected 0 -> 10, got %d -> %d", stop.Watch.OldValue, stop.Watch.NewValue)
+	}
+
+	stop, _ = cpu.Run(mem, 0)
+
+	if stop.Kind != STOP_WATCHPOINT || stop.Watch.OldValue != 10 || stop.Watch.NewValue != 20 {
+		t.Errorf("Expected second write 10 -> 20, got %+v", stop)
+	}
+}
+
+func TestWatchpointRead(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"STORE 5 10",
+		"LOADM R0 5",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.AddWatchpoint(5, WATCH_READ)
+	cpu.Reset()
+
+	stop, err := cpu.Run(mem, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error running: %s", err)
+	}
+
+	if stop.Kind != STOP_WATCHPOINT || stop.Watch.Access != WATCH_READ || stop.Steps != 2 {
+		t.Errorf("Expected the LOADM to trigger a read watchpoint, got %+v", stop)
+	}
+
+	if stop.Watch.NewValue != 10 {
+		t.Errorf("Expected watched value to be 10, got %d", stop.Watch.NewValue)
+	}
+}
@@ -1,6 +1,9 @@
 package cpu
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 type AssemblerErrorType string
 
@@ -11,6 +14,8 @@ const (
 	INVALID_REGISTER                         = "invalid register"
 	INVALID_LABEL                            = "invalid label"
 	INVALID_OPCODE                           = "invalid opcode"
+	INVALID_DIRECTIVE                        = "invalid directive"
+	CODE_TOO_LARGE                           = "code too large"
 )
 
 type AssemblerError struct {
@@ -40,3 +45,72 @@ func NewAssemblerError(
 func (e *AssemblerError) Error() string {
 	return fmt.Sprintf("Assembler error on line %d: %s - %s", e.Line+1, e.Type, e.Message)
 }
+
+// ErrHalted is returned when stepping a CPU that has already halted
+var ErrHalted = errors.New("cpu is halted")
+
+// ErrStopped is returned by ExecuteContext when a breakpoint or watchpoint
+// stops the program before it halts
+var ErrStopped = errors.New("execution stopped")
+
+type RuntimeErrorType string
+
+const (
+	UNKNOWN_OPCODE         RuntimeErrorType = "unknown opcode"
+	INVALID_REGISTER_INDEX RuntimeErrorType = "invalid register index"
+	MEMORY_OUT_OF_BOUNDS   RuntimeErrorType = "memory access out of bounds"
+	STACK_OVERFLOW         RuntimeErrorType = "stack overflow"
+	STACK_UNDERFLOW        RuntimeErrorType = "stack underflow"
+	DIVIDE_BY_ZERO         RuntimeErrorType = "divide by zero"
+	IO_ERROR               RuntimeErrorType = "input/output error"
+	UNHANDLED_INTERRUPT    RuntimeErrorType = "unhandled interrupt"
+	BUS_ERROR              RuntimeErrorType = "bus error"
+	PROTECTION_FAULT       RuntimeErrorType = "protection fault"
+	PAGE_FAULT             RuntimeErrorType = "page fault"
+	PRIVILEGED_INSTRUCTION RuntimeErrorType = "privileged instruction"
+
+	INSTRUCTION_LIMIT_EXCEEDED RuntimeErrorType = "instruction limit exceeded"
+	TIME_LIMIT_EXCEEDED        RuntimeErrorType = "time limit exceeded"
+	OUTPUT_LIMIT_EXCEEDED      RuntimeErrorType = "output limit exceeded"
+	STACK_LIMIT_EXCEEDED       RuntimeErrorType = "stack limit exceeded"
+)
+
+// Error lets each RuntimeErrorType be used as a sentinel with errors.Is
+func (t RuntimeErrorType) Error() string {
+	return string(t)
+}
+
+// RuntimeError describes a fault raised while executing a program, along with
+// a snapshot of the CPU at the faulting instruction.
+type RuntimeError struct {
+	Type           RuntimeErrorType
+	ProgramCounter uint16
+	Opcode         Opcode
+	Operands       []uint8
+	Registers      [RegisterCount]uint8
+	Flags          Flags
+	StackPointer   uint16
+	Err            error
+}
+
+func (e *RuntimeError) Error() string {
+	message := fmt.Sprintf(
+		"Runtime error at address %d (opcode %d): %s",
+		e.ProgramCounter,
+		e.Opcode,
+		e.Type,
+	)
+	if e.Err != nil && e.Err != e.Type {
+		message += " - " + e.Err.Error()
+	}
+	return message
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RuntimeError) Is(target error) bool {
+	t, ok := target.(RuntimeErrorType)
+	return ok && t == e.Type
+}
@@ -1,62 +1,127 @@
 package cpu
 
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
 type Opcode uint8
 
 const (
-	OP_LOAD_RV  Opcode = iota // Load a value into a register
-	OP_LOAD_RR                // Load a register into another register
-	OP_LOADM_RA               // Load a value from stored memory into a register
-	OP_STORE_RA               // Stores a register in stored memory
-	OP_STORE_AV               // Stores a value in stored memory
-	OP_STORE_RR               // Stores register2 in the address that register1 contains
-	OP_ADD_RR                 // Add the right register to the left register, storing the result in the left register
-	OP_ADD_RV                 // Add the register and value together, storing in the register
-	OP_SUB_RR                 // Subtract the right register from the left register, storing the result in the left register
-	OP_SUB_RV                 // Subtract the value from the register, storing in the register
-	OP_MUL_RR                 // Multiply the left register by the right register, storing the result in the left register
-	OP_MUL_RV                 // Multiply the register by the value, storing in the register
-	OP_DIV_RR                 // Divide the left register by the right register, storing the result in the left register
-	OP_DIV_RV                 // Divide the register by the value, storing in the register
-	OP_MOD_RR                 // Modulo the left register by the right register, storing the result in the left register
-	OP_MOD_RV                 // Modulo the register by the value, storing in the register
-	OP_AND_RR                 // Bitwise AND the left register with the right register, storing the result in the left register
-	OP_AND_RV                 // Bitwise AND the register with the value, storing in the register
-	OP_OR_RR                  // Bitwise OR the left register with the right register, storing the result in the left register
-	OP_OR_RV                  // Bitwise OR the register with the value, storing in the register
-	OP_XOR_RR                 // Bitwise XOR the left register with the right register, storing the result in the left register
-	OP_XOR_RV                 // Bitwise XOR the register with the value, storing in the register
-	OP_NOT_R                  // Bitwise NOT a register
-	OP_SHL_R                  // Bitwise shift left a register
-	OP_SHR_R                  // Bitwise shift right a register
-	OP_INC_R                  // Increment a register
-	OP_DEC_R                  // Decrement a register
-	OP_PUSH_R                 // Push a register onto the stack
-	OP_PUSH_V                 // Push a value onto the stack
-	OP_POP_NONE               // Pop a value off the stack
-	OP_POP_R                  // Pop a value off the stack into a register
-	OP_CMP_RR                 // Compare two registers, setting the flags
-	OP_CMP_RV                 // Compare a register to a value, setting the flags
-	OP_JMP_A                  // Jump to an address
-	OP_JMP_R                  // Jump to a register
-	OP_JE_A                   // Jump if equal to an address
-	OP_JE_R                   // Jump if equal to an register
-	OP_JNE_A                  // Jump if not equal to an address
-	OP_JNE_R                  // Jump if not equal to an register
-	OP_JG_A                   // Jump if greater than an address
-	OP_JG_R                   // Jump if greater than an register
-	OP_JGE_A                  // Jump if greater than or equal to an address
-	OP_JGE_R                  // Jump if greater than or equal to an register
-	OP_JL_A                   // Jump if less than an address
-	OP_JL_R                   // Jump if less than an register
-	OP_JLE_A                  // Jump if less than or equal to an address
-	OP_JLE_R                  // Jump if less than or equal to an register
-	OP_CALL_A                 // Call a function at an address, pushing the next instruction onto the stack
-	OP_CALL_R                 // Call a function at a register, pushing the next instruction onto the stack
-	OP_RET_NONE               // Return from a function, popping the return address off the stack and jumping to it
-	OP_PRINT_V                // Print a value
-	OP_PRINT_R                // Print a register
-	OP_PRINTS_A               // Print a string from stored memory
-	OP_HLT_NONE               // Halt execution
+	OP_LOAD_RV    Opcode = iota // Load a value into a register
+	OP_LOAD_RR                  // Load a register into another register
+	OP_LOADM_RA                 // Load a value from memory into a register
+	OP_STORE_RA                 // Stores a register in memory
+	OP_STORE_AV                 // Stores a value in memory
+	OP_STORE_RR                 // Stores register2 in the address that register1 contains
+	OP_ADD_RR                   // Add the right register to the left register, storing the result in the left register
+	OP_ADD_RV                   // Add the register and value together, storing in the register
+	OP_SUB_RR                   // Subtract the right register from the left register, storing the result in the left register
+	OP_SUB_RV                   // Subtract the value from the register, storing in the register
+	OP_MUL_RR                   // Multiply the left register by the right register, storing the result in the left register
+	OP_MUL_RV                   // Multiply the register by the value, storing in the register
+	OP_DIV_RR                   // Divide the left register by the right register, storing the result in the left register
+	OP_DIV_RV                   // Divide the register by the value, storing in the register
+	OP_MOD_RR                   // Modulo the left register by the right register, storing the result in the left register
+	OP_MOD_RV                   // Modulo the register by the value, storing in the register
+	OP_AND_RR                   // Bitwise AND the left register with the right register, storing the result in the left register
+	OP_AND_RV                   // Bitwise AND the register with the value, storing in the register
+	OP_OR_RR                    // Bitwise OR the left register with the right register, storing the result in the left register
+	OP_OR_RV                    // Bitwise OR the register with the value, storing in the register
+	OP_XOR_RR                   // Bitwise XOR the left register with the right register, storing the result in the left register
+	OP_XOR_RV                   // Bitwise XOR the register with the value, storing in the register
+	OP_NOT_R                    // Bitwise NOT a register
+	OP_SHL_R                    // Bitwise shift left a register
+	OP_SHR_R                    // Bitwise shift right a register
+	OP_INC_R                    // Increment a register
+	OP_DEC_R                    // Decrement a register
+	OP_PUSH_R                   // Push a register onto the stack
+	OP_PUSH_V                   // Push a value onto the stack
+	OP_POP_NONE                 // Pop a value off the stack
+	OP_POP_R                    // Pop a value off the stack into a register
+	OP_CMP_RR                   // Compare two registers, setting the flags
+	OP_CMP_RV                   // Compare a register to a value, setting the flags
+	OP_JMP_A                    // Jump to an address
+	OP_JMP_R                    // Jump to a register
+	OP_JE_A                     // Jump if equal to an address
+	OP_JE_R                     // Jump if equal to an register
+	OP_JNE_A                    // Jump if not equal to an address
+	OP_JNE_R                    // Jump if not equal to an register
+	OP_JG_A                     // Jump if greater than an address
+	OP_JG_R                     // Jump if greater than an register
+	OP_JGE_A                    // Jump if greater than or equal to an address
+	OP_JGE_R                    // Jump if greater than or equal to an register
+	OP_JL_A                     // Jump if less than an address
+	OP_JL_R                     // Jump if less than an register
+	OP_JLE_A                    // Jump if less than or equal to an address
+	OP_JLE_R                    // Jump if less than or equal to an register
+	OP_CALL_A                   // Call a function at an address, pushing the next instruction onto the stack
+	OP_CALL_R                   // Call a function at a register, pushing the next instruction onto the stack
+	OP_RET_NONE                 // Return from a function, popping the return address off the stack and jumping to it
+	OP_PRINT_V                  // Print a value
+	OP_PRINT_R                  // Print a register
+	OP_PRINTS_A                 // Print a string from memory
+	OP_HLT_NONE                 // Halt execution
+	OP_READ_R                   // Read a byte of input into a register, reading 0 at end of input
+	OP_READS_A                  // Read a line of input into memory as a null-terminated string
+	OP_PRINTC_R                 // Print a register as a character
+	OP_PRINTC_V                 // Print a value as a character
+	OP_JZ_A                     // Jump if the zero flag is set to an address
+	OP_JZ_R                     // Jump if the zero flag is set to a register
+	OP_JNZ_A                    // Jump if the zero flag is not set to an address
+	OP_JNZ_R                    // Jump if the zero flag is not set to a register
+	OP_JC_A                     // Jump if the carry flag is set to an address
+	OP_JC_R                     // Jump if the carry flag is set to a register
+	OP_JNC_A                    // Jump if the carry flag is not set to an address
+	OP_JNC_R                    // Jump if the carry flag is not set to a register
+	OP_JO_A                     // Jump if the overflow flag is set to an address
+	OP_JO_R                     // Jump if the overflow flag is set to a register
+	OP_JS_A                     // Jump if the sign flag is set to an address
+	OP_JS_R                     // Jump if the sign flag is set to a register
+	OP_ADC_RR                   // Add the right register and the carry flag to the left register, storing the result in the left register
+	OP_ADC_RV                   // Add the value and the carry flag to the register, storing in the register
+	OP_SBB_RR                   // Subtract the right register and the carry flag from the left register, storing the result in the left register
+	OP_SBB_RV                   // Subtract the value and the carry flag from the register, storing in the register
+	OP_SCMP_RR                  // Compare two registers as signed numbers, setting the flags
+	OP_SCMP_RV                  // Compare a register to a value as signed numbers, setting the flags
+	OP_JSG_A                    // Jump if signed greater than to an address
+	OP_JSG_R                    // Jump if signed greater than to a register
+	OP_JSGE_A                   // Jump if signed greater than or equal to an address
+	OP_JSGE_R                   // Jump if signed greater than or equal to a register
+	OP_JSL_A                    // Jump if signed less than to an address
+	OP_JSL_R                    // Jump if signed less than to a register
+	OP_JSLE_A                   // Jump if signed less than or equal to an address
+	OP_JSLE_R                   // Jump if signed less than or equal to a register
+	OP_IDIV_RR                  // Signed divide the left register by the right register, storing the result in the left register
+	OP_IDIV_RV                  // Signed divide the register by the value, storing in the register
+	OP_IMOD_RR                  // Signed modulo the left register by the right register, storing the result in the left register
+	OP_IMOD_RV                  // Signed modulo the register by the value, storing in the register
+	OP_SAR_R                    // Arithmetic shift right a register, keeping its sign
+	OP_SEXT_RR                  // Sign extend the right register, storing the high byte (0 or 255) in the left register
+	OP_LOADSP_RV                // Load the stack byte at an offset from the top of the stack into a register
+	OP_STORESP_RV               // Store a register at an offset from the top of the stack
+	OP_ADDSP_V                  // Drop a number of bytes off the stack
+	OP_SUBSP_V                  // Reserve a number of bytes on the stack
+	OP_EI_NONE                  // Enable interrupts
+	OP_DI_NONE                  // Disable interrupts
+	OP_IRET_NONE                // Return from an interrupt handler, restoring the flags and program counter
+	OP_IN_RV                    // Read a byte from an I/O port into a register
+	OP_OUT_VR                   // Write a register to an I/O port
+	OP_SNAP_NONE                // Take a snapshot of the attached displays
+
+	// System calls, see privilege.go
+	OP_SYSCALL_NONE // Call the system call handler in supervisor mode
+	OP_SYSRET_NONE  // Return from a system call to the saved mode
+
+	// Register pairs, holding a full address with the high byte first. This is
+	// the one place an address isn't little-endian, so that a pair reads like
+	// the number it holds.
+	OP_JMPP_R    // Jump to the address in the register pair starting at the register
+	OP_CALLP_R   // Call the address in the register pair starting at the register
+	OP_LOADP_RR  // Load the left register from the address in the register pair starting at the right register
+	OP_STOREP_RR // Store the right register at the address in the register pair starting at the left register
 )
 
 type InstructionType uint8
@@ -71,6 +136,7 @@ const (
 	INST_AV
 	INST_V
 	INST_NONE
+	INST_VR
 
 	INST_AL
 	INST_RL
@@ -82,80 +148,258 @@ type OpcodeKey struct {
 }
 
 var OpcodeMap = map[OpcodeKey]Opcode{
-	{"LOAD", INST_RV}:  OP_LOAD_RV,
-	{"LOAD", INST_RR}:  OP_LOAD_RR,
-	{"LOADM", INST_RA}: OP_LOADM_RA,
-	{"STORE", INST_RA}: OP_STORE_RA,
-	{"STORE", INST_AV}: OP_STORE_AV,
-	{"STORE", INST_RR}: OP_STORE_RR,
-	{"ADD", INST_RR}:   OP_ADD_RR,
-	{"ADD", INST_RV}:   OP_ADD_RV,
-	{"SUB", INST_RR}:   OP_SUB_RR,
-	{"SUB", INST_RV}:   OP_SUB_RV,
-	{"MUL", INST_RR}:   OP_MUL_RR,
-	{"MUL", INST_RV}:   OP_MUL_RV,
-	{"DIV", INST_RR}:   OP_DIV_RR,
-	{"DIV", INST_RV}:   OP_DIV_RV,
-	{"MOD", INST_RR}:   OP_MOD_RR,
-	{"MOD", INST_RV}:   OP_MOD_RV,
-	{"AND", INST_RR}:   OP_AND_RR,
-	{"AND", INST_RV}:   OP_AND_RV,
-	{"OR", INST_RR}:    OP_OR_RR,
-	{"OR", INST_RV}:    OP_OR_RV,
-	{"XOR", INST_RR}:   OP_XOR_RR,
-	{"XOR", INST_RV}:   OP_XOR_RV,
-	{"NOT", INST_R}:    OP_NOT_R,
-	{"SHL", INST_R}:    OP_SHL_R,
-	{"SHR", INST_R}:    OP_SHR_R,
-	{"INC", INST_R}:    OP_INC_R,
-	{"DEC", INST_R}:    OP_DEC_R,
-	{"PUSH", INST_R}:   OP_PUSH_R,
-	{"PUSH", INST_V}:   OP_PUSH_V,
-	{"POP", INST_NONE}: OP_POP_NONE,
-	{"POP", INST_R}:    OP_POP_R,
-	{"CMP", INST_RR}:   OP_CMP_RR,
-	{"CMP", INST_RV}:   OP_CMP_RV,
-	{"JMP", INST_AL}:   OP_JMP_A,
-	{"JMP", INST_A}:    OP_JMP_A,
-	{"JMP", INST_R}:    OP_JMP_R,
-	{"JE", INST_AL}:    OP_JE_A,
-	{"JE", INST_A}:     OP_JE_A,
-	{"JE", INST_R}:     OP_JE_R,
-	{"JNE", INST_AL}:   OP_JNE_A,
-	{"JNE", INST_A}:    OP_JNE_A,
-	{"JNE", INST_R}:    OP_JNE_R,
-	{"JG", INST_AL}:    OP_JG_A,
-	{"JG", INST_A}:     OP_JG_A,
-	{"JG", INST_R}:     OP_JG_R,
-	{"JGE", INST_AL}:   OP_JGE_A,
-	{"JGE", INST_A}:    OP_JGE_A,
-	{"JGE", INST_R}:    OP_JGE_R,
-	{"JL", INST_AL}:    OP_JL_A,
-	{"JL", INST_A}:     OP_JL_A,
-	{"JL", INST_R}:     OP_JL_R,
-	{"JLE", INST_AL}:   OP_JLE_A,
-	{"JLE", INST_A}:    OP_JLE_A,
-	{"JLE", INST_R}:    OP_JLE_R,
-	{"CALL", INST_AL}:  OP_CALL_A,
-	{"CALL", INST_A}:   OP_CALL_A,
-	{"CALL", INST_R}:   OP_CALL_R,
-	{"RET", INST_NONE}: OP_RET_NONE,
-	{"PRINT", INST_V}:  OP_PRINT_V,
-	{"PRINT", INST_R}:  OP_PRINT_R,
-	{"PRINTS", INST_A}: OP_PRINTS_A,
-	{"HLT", INST_NONE}: OP_HLT_NONE,
+	{"LOAD", INST_RV}:    OP_LOAD_RV,
+	{"LOAD", INST_RR}:    OP_LOAD_RR,
+	{"LOADM", INST_RA}:   OP_LOADM_RA,
+	{"STORE", INST_RA}:   OP_STORE_RA,
+	{"STORE", INST_AV}:   OP_STORE_AV,
+	{"STORE", INST_RR}:   OP_STORE_RR,
+	{"ADD", INST_RR}:     OP_ADD_RR,
+	{"ADD", INST_RV}:     OP_ADD_RV,
+	{"SUB", INST_RR}:     OP_SUB_RR,
+	{"SUB", INST_RV}:     OP_SUB_RV,
+	{"MUL", INST_RR}:     OP_MUL_RR,
+	{"MUL", INST_RV}:     OP_MUL_RV,
+	{"DIV", INST_RR}:     OP_DIV_RR,
+	{"DIV", INST_RV}:     OP_DIV_RV,
+	{"MOD", INST_RR}:     OP_MOD_RR,
+	{"MOD", INST_RV}:     OP_MOD_RV,
+	{"AND", INST_RR}:     OP_AND_RR,
+	{"AND", INST_RV}:     OP_AND_RV,
+	{"OR", INST_RR}:      OP_OR_RR,
+	{"OR", INST_RV}:      OP_OR_RV,
+	{"XOR", INST_RR}:     OP_XOR_RR,
+	{"XOR", INST_RV}:     OP_XOR_RV,
+	{"NOT", INST_R}:      OP_NOT_R,
+	{"SHL", INST_R}:      OP_SHL_R,
+	{"SHR", INST_R}:      OP_SHR_R,
+	{"INC", INST_R}:      OP_INC_R,
+	{"DEC", INST_R}:      OP_DEC_R,
+	{"PUSH", INST_R}:     OP_PUSH_R,
+	{"PUSH", INST_V}:     OP_PUSH_V,
+	{"POP", INST_NONE}:   OP_POP_NONE,
+	{"POP", INST_R}:      OP_POP_R,
+	{"CMP", INST_RR}:     OP_CMP_RR,
+	{"CMP", INST_RV}:     OP_CMP_RV,
+	{"JMP", INST_AL}:     OP_JMP_A,
+	{"JMP", INST_A}:      OP_JMP_A,
+	{"JMP", INST_R}:      OP_JMP_R,
+	{"JE", INST_AL}:      OP_JE_A,
+	{"JE", INST_A}:       OP_JE_A,
+	{"JE", INST_R}:       OP_JE_R,
+	{"JNE", INST_AL}:     OP_JNE_A,
+	{"JNE", INST_A}:      OP_JNE_A,
+	{"JNE", INST_R}:      OP_JNE_R,
+	{"JG", INST_AL}:      OP_JG_A,
+	{"JG", INST_A}:       OP_JG_A,
+	{"JG", INST_R}:       OP_JG_R,
+	{"JGE", INST_AL}:     OP_JGE_A,
+	{"JGE", INST_A}:      OP_JGE_A,
+	{"JGE", INST_R}:      OP_JGE_R,
+	{"JL", INST_AL}:      OP_JL_A,
+	{"JL", INST_A}:       OP_JL_A,
+	{"JL", INST_R}:       OP_JL_R,
+	{"JLE", INST_AL}:     OP_JLE_A,
+	{"JLE", INST_A}:      OP_JLE_A,
+	{"JLE", INST_R}:      OP_JLE_R,
+	{"CALL", INST_AL}:    OP_CALL_A,
+	{"CALL", INST_A}:     OP_CALL_A,
+	{"CALL", INST_R}:     OP_CALL_R,
+	{"RET", INST_NONE}:   OP_RET_NONE,
+	{"PRINT", INST_V}:    OP_PRINT_V,
+	{"PRINT", INST_R}:    OP_PRINT_R,
+	{"PRINTS", INST_A}:   OP_PRINTS_A,
+	{"HLT", INST_NONE}:   OP_HLT_NONE,
+	{"READ", INST_R}:     OP_READ_R,
+	{"READS", INST_A}:    OP_READS_A,
+	{"PRINTC", INST_R}:   OP_PRINTC_R,
+	{"PRINTC", INST_V}:   OP_PRINTC_V,
+	{"JZ", INST_AL}:      OP_JZ_A,
+	{"JZ", INST_A}:       OP_JZ_A,
+	{"JZ", INST_R}:       OP_JZ_R,
+	{"JNZ", INST_AL}:     OP_JNZ_A,
+	{"JNZ", INST_A}:      OP_JNZ_A,
+	{"JNZ", INST_R}:      OP_JNZ_R,
+	{"JC", INST_AL}:      OP_JC_A,
+	{"JC", INST_A}:       OP_JC_A,
+	{"JC", INST_R}:       OP_JC_R,
+	{"JNC", INST_AL}:     OP_JNC_A,
+	{"JNC", INST_A}:      OP_JNC_A,
+	{"JNC", INST_R}:      OP_JNC_R,
+	{"JO", INST_AL}:      OP_JO_A,
+	{"JO", INST_A}:       OP_JO_A,
+	{"JO", INST_R}:       OP_JO_R,
+	{"JS", INST_AL}:      OP_JS_A,
+	{"JS", INST_A}:       OP_JS_A,
+	{"JS", INST_R}:       OP_JS_R,
+	{"ADC", INST_RR}:     OP_ADC_RR,
+	{"ADC", INST_RV}:     OP_ADC_RV,
+	{"SBB", INST_RR}:     OP_SBB_RR,
+	{"SBB", INST_RV}:     OP_SBB_RV,
+	{"SCMP", INST_RR}:    OP_SCMP_RR,
+	{"SCMP", INST_RV}:    OP_SCMP_RV,
+	{"JSG", INST_AL}:     OP_JSG_A,
+	{"JSG", INST_A}:      OP_JSG_A,
+	{"JSG", INST_R}:      OP_JSG_R,
+	{"JSGE", INST_AL}:    OP_JSGE_A,
+	{"JSGE", INST_A}:     OP_JSGE_A,
+	{"JSGE", INST_R}:     OP_JSGE_R,
+	{"JSL", INST_AL}:     OP_JSL_A,
+	{"JSL", INST_A}:      OP_JSL_A,
+	{"JSL", INST_R}:      OP_JSL_R,
+	{"JSLE", INST_AL}:    OP_JSLE_A,
+	{"JSLE", INST_A}:     OP_JSLE_A,
+	{"JSLE", INST_R}:     OP_JSLE_R,
+	{"IDIV", INST_RR}:    OP_IDIV_RR,
+	{"IDIV", INST_RV}:    OP_IDIV_RV,
+	{"IMOD", INST_RR}:    OP_IMOD_RR,
+	{"IMOD", INST_RV}:    OP_IMOD_RV,
+	{"SAR", INST_R}:      OP_SAR_R,
+	{"SEXT", INST_RR}:    OP_SEXT_RR,
+	{"LOADSP", INST_RV}:  OP_LOADSP_RV,
+	{"STORESP", INST_RV}: OP_STORESP_RV,
+	{"ADDSP", INST_V}:    OP_ADDSP_V,
+	{"SUBSP", INST_V}:    OP_SUBSP_V,
+	{"EI", INST_NONE}:    OP_EI_NONE,
+	{"DI", INST_NONE}:    OP_DI_NONE,
+	{"IRET", INST_NONE}:  OP_IRET_NONE,
+	{"IN", INST_RV}:      OP_IN_RV,
+	{"OUT", INST_VR}:     OP_OUT_VR,
+	{"SNAP", INST_NONE}:  OP_SNAP_NONE,
+
+	// System calls, see privilege.go
+	{"SYSCALL", INST_NONE}: OP_SYSCALL_NONE,
+	{"SYSRET", INST_NONE}:  OP_SYSRET_NONE,
+
+	// Register pairs
+	{"JMPP", INST_R}:    OP_JMPP_R,
+	{"CALLP", INST_R}:   OP_CALLP_R,
+	{"LOADP", INST_RR}:  OP_LOADP_RR,
+	{"STOREP", INST_RR}: OP_STOREP_RR,
 }
 
+// Addresses are encoded as two bytes, low byte first
 var InstructionSizeMap = map[InstructionType]int{
 	INST_RR:   3,
 	INST_RV:   3,
-	INST_RA:   3,
-	INST_AV:   3,
-	INST_A:    2,
+	INST_RA:   4,
+	INST_AV:   4,
+	INST_A:    3,
 	INST_V:    2,
 	INST_R:    2,
 	INST_NONE: 1,
+	INST_VR:   3,
+
+	INST_AL: 3,
+	INST_RL: 3,
+}
+
+// opcodeKeys is the reverse of OpcodeMap, used to decode instructions. Label
+// forms assemble to the same bytes as their address forms, so they are skipped.
+var opcodeKeys = func() map[Opcode]OpcodeKey {
+	keys := make(map[Opcode]OpcodeKey)
+	for key, opcode := range OpcodeMap {
+		if key.Type == INST_AL || key.Type == INST_RL {
+			continue
+		}
+		keys[opcode] = key
+	}
+	return keys
+}()
+
+// Instruction is a single decoded instruction
+type Instruction struct {
+	Address  uint16
+	Opcode   Opcode
+	Name     string
+	Type     InstructionType
+	Operands []uint8
+}
+
+// Size returns the number of bytes the instruction occupies in memory
+func (i Instruction) Size() int {
+	return InstructionSizeMap[i.Type]
+}
+
+func (i Instruction) String() string {
+	if len(i.Operands) != i.Size()-1 {
+		return i.Name
+	}
+
+	register := func(n int) string {
+		return fmt.Sprintf("R%d", i.Operands[n])
+	}
+	value := func(n int) string {
+		return strconv.Itoa(int(i.Operands[n]))
+	}
+	address := func(n int) string {
+		return strconv.Itoa(int(uint16(i.Operands[n+1])<<8 | uint16(i.Operands[n])))
+	}
+
+	parts := []string{i.Name}
+	switch i.Type {
+	case INST_R:
+		parts = append(parts, register(0))
+	case INST_RR:
+		parts = append(parts, register(0), register(1))
+	case INST_RA:
+		parts = append(parts, register(0), address(1))
+	case INST_RV:
+		parts = append(parts, register(0), value(1))
+	case INST_A:
+		parts = append(parts, address(0))
+	case INST_AV:
+		parts = append(parts, address(0), value(2))
+	case INST_V:
+		parts = append(parts, value(0))
+	case INST_VR:
+		parts = append(parts, value(0), register(1))
+	}
+	return strings.Join(parts, " ")
+}
+
+// fetchedInstruction describes the instruction at address from the bytes
+// fetched to run it, which are fewer than its size if fetching it failed
+func fetchedInstruction(address uint16, code []uint8) Instruction {
+	if len(code) == 0 {
+		return Instruction{Address: address}
+	}
+	instruction := Instruction{
+		Address:  address,
+		Opcode:   Opcode(code[0]),
+		Operands: slices.Clone(code[1:]),
+	}
+	if key, ok := opcodeKeys[instruction.Opcode]; ok {
+		instruction.Name, instruction.Type = key.OpcodeName, key.Type
+	}
+	return instruction
+}
+
+// Decode reads the instruction at address without executing it
+func Decode(bus Bus, address uint16) (Instruction, error) {
+	opcode, err := readCode(bus, address)
+	if err != nil {
+		return Instruction{Address: address}, err
+	}
+
+	key, ok := opcodeKeys[Opcode(opcode)]
+	if !ok {
+		return Instruction{Address: address, Opcode: Opcode(opcode)}, UNKNOWN_OPCODE
+	}
+
+	instruction := Instruction{
+		Address: address,
+		Opcode:  Opcode(opcode),
+		Name:    key.OpcodeName,
+		Type:    key.Type,
+	}
+	for n := 1; n < instruction.Size(); n++ {
+		operand, err := readCode(bus, address+uint16(n))
+		if err != nil {
+			return instruction, err
+		}
+		instruction.Operands = append(instruction.Operands, operand)
+	}
 
-	INST_AL: 2,
-	INST_RL: 2,
+	return instruction, nil
 }
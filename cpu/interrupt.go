@@ -0,0 +1,208 @@
+package cpu
+
+import "fmt"
+
+// The vector table holds a two byte little-endian handler address for each
+// vector. Vectors 0 to 7 are the interrupt lines and the rest are reserved for
+// traps raised by the CPU itself. By default the table sits just below the
+// stack, see Layout.VectorBase.
+const (
+	InterruptLineCount = 8
+	VectorCount        = 16
+	DefaultVectorBase  = DefaultStackLimit - VectorCount*2
+)
+
+// Trap vectors, raised by the CPU itself
+const (
+	PageFaultVector = InterruptLineCount + iota // The MMU refused an access, see mmu.go
+	PrivilegeVector                             // A privileged instruction ran in user mode, see privilege.go
+	SyscallVector                               // SYSCALL was executed
+)
+
+// faultVectors are the trap vectors of faults that are trapped with the
+// faulting instruction undone
+var faultVectors = map[RuntimeErrorType]uint8{
+	PAGE_FAULT:             PageFaultVector,
+	PRIVILEGED_INSTRUCTION: PrivilegeVector,
+}
+
+// interruptsEnabledBit is where the interrupt enable state is saved alongside
+// the flags when entering a handler
+const interruptsEnabledBit = 1 << 7
+
+// RaiseIRQ marks an interrupt line as pending. It is safe to call from other
+// goroutines, so devices can raise interrupts asynchronously. Lines past
+// InterruptLineCount are ignored.
+func (c *CPU) RaiseIRQ(line uint8) {
+	if line >= InterruptLineCount {
+		return
+	}
+	for {
+		pending := c.pendingIRQs.Load()
+		if c.pendingIRQs.CompareAndSwap(pending, pending|1<<line) {
+			return
+		}
+	}
+}
+
+// PendingIRQs returns a bit set of the interrupt lines waiting to be serviced
+func (c *CPU) PendingIRQs() uint8 {
+	return uint8(c.pendingIRQs.Load())
+}
+
+// clearIRQs drops the pending interrupt lines set in lines
+func (c *CPU) clearIRQs(lines uint8) {
+	for {
+		pending := c.pendingIRQs.Load()
+		if c.pendingIRQs.CompareAndSwap(pending, pending&^uint32(lines)) {
+			return
+		}
+	}
+}
+
+// SetVector writes the handler address for a vector into the vector table
+func (c *CPU) SetVector(bus Bus, vector uint8, address uint16) error {
+	if vector >= VectorCount {
+		return fmt.Errorf("vector %d out of range", vector)
+	}
+	entry := c.VectorBase + uint16(vector)*2
+	if err := bus.Write(entry, uint8(address)); err != nil {
+		return err
+	}
+	return bus.Write(entry+1, uint8(address>>8))
+}
+
+// serviceInterrupts enters the handler of the lowest numbered pending line
+// that is not masked, if interrupts are enabled
+func (c *CPU) serviceInterrupts(bus Bus) error {
+	if !c.InterruptsEnabled {
+		return nil
+	}
+
+	ready := c.PendingIRQs() &^ c.InterruptMask
+	if ready == 0 {
+		return nil
+	}
+
+	var line uint8
+	for ready&(1<<line) == 0 {
+		line++
+	}
+
+	c.clearIRQs(1 << line)
+
+	if err := c.enterHandler(bus, line); err != nil {
+		return c.errorAtProgramCounter(bus, err)
+	}
+	return nil
+}
+
+// handlerAddress reads the handler address of a vector from the vector table
+func (c *CPU) handlerAddress(bus Bus, vector uint8) (uint16, error) {
+	entry := c.VectorBase + uint16(vector)*2
+	low, err := c.readMemory(bus, entry)
+	if err != nil {
+		return 0, err
+	}
+	high, err := c.readMemory(bus, entry+1)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(high)<<8 | uint16(low), nil
+}
+
+// enterHandler enters the handler for vector, which must have one
+func (c *CPU) enterHandler(bus Bus, vector uint8) error {
+	trapped, err := c.trap(bus, vector)
+	if err == nil && !trapped {
+		return fmt.Errorf("%w: no handler for vector %d", UNHANDLED_INTERRUPT, vector)
+	}
+	return err
+}
+
+// trap saves the program counter, flags and mode on the supervisor stack,
+// disables interrupts and jumps to the handler for vector in supervisor mode.
+// It reports false, leaving the CPU as it was, if the vector has no handler.
+func (c *CPU) trap(bus Bus, vector uint8) (bool, error) {
+	// The vector table and handler stack belong to the supervisor
+	user := c.UserMode
+	c.setMode(bus, false)
+	handler, err := c.handlerAddress(bus, vector)
+	if err != nil || handler == 0 {
+		c.setMode(bus, user)
+		return false, err
+	}
+
+	stackPointer := c.StackPointer
+	if err := c.pushFrame(bus, user); err != nil {
+		// Don't leave user code running with supervisor privileges
+		c.StackPointer = stackPointer
+		c.setMode(bus, user)
+		return true, err
+	}
+
+	c.InterruptsEnabled = false
+	c.ProgramCounter = handler
+	return true, nil
+}
+
+// pushFrame pushes the program counter, flags and mode for returnFromInterrupt
+func (c *CPU) pushFrame(bus Bus, user bool) error {
+	if err := c.reservePush(bus, 4); err != nil {
+		return err
+	}
+	if err := c.pushAddress(bus, c.ProgramCounter); err != nil {
+		return err
+	}
+	flags := c.Flags.pack()
+	if c.InterruptsEnabled {
+		flags |= interruptsEnabledBit
+	}
+	if err := c.push(bus, flags); err != nil {
+		return err
+	}
+	return c.push(bus, modeStatus(user))
+}
+
+// returnFromInterrupt restores the mode, flags, interrupt enable state and
+// program counter saved by trap
+func (c *CPU) returnFromInterrupt(bus Bus) error {
+	if c.StackDepth() < 4 {
+		return STACK_UNDERFLOW
+	}
+	status, err := c.pop(bus)
+	if err != nil {
+		return err
+	}
+	flags, err := c.pop(bus)
+	if err != nil {
+		return err
+	}
+	address, err := c.popAddress(bus)
+	if err != nil {
+		return err
+	}
+
+	c.Flags = unpackFlags(flags)
+	c.InterruptsEnabled = flags&interruptsEnabledBit != 0
+	c.ProgramCounter = address
+	c.setMode(bus, status&statusUserMode != 0)
+	return nil
+}
+
+// pack stores the flags in the low seven bits of a byte
+func (f Flags) pack() uint8 {
+	return f.Equal | f.Greater<<1 | f.Less<<2 | f.Zero<<3 | f.Carry<<4 | f.Overflow<<5 | f.Sign<<6
+}
+
+func unpackFlags(b uint8) Flags {
+	return Flags{
+		Equal:    b & 1,
+		Greater:  b >> 1 & 1,
+		Less:     b >> 2 & 1,
+		Zero:     b >> 3 & 1,
+		Carry:    b >> 4 & 1,
+		Overflow: b >> 5 & 1,
+		Sign:     b >> 6 & 1,
+	}
+}
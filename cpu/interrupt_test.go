@@ -0,0 +1,253 @@
+package cpu
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestInterruptHandler(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"STORE 0xFEE2 <handler",
+		"STORE 0xFEE3 >handler",
+		"LOAD R0 5",
+		"CMP R0 5",
+		"EI",
+		"LOAD R1 1",
+		"HLT",
+		"handler:",
+		"LOAD R2 7",
+		"CMP R2 9",
+		"IRET",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.RaiseIRQ(1)
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.Registers[2] != 7 {
+		t.Errorf("Expected the handler to run, got R2 %d", cpu.Registers[2])
+	}
+
+	if cpu.Registers[1] != 1 {
+		t.Errorf("Expected execution to resume after the handler, got R1 %d", cpu.Registers[1])
+	}
+
+	if cpu.Flags.Equal != 1 || cpu.Flags.Less != 0 {
+		t.Errorf("Expected the flags to be restored, got %+v", cpu.Flags)
+	}
+
+	if !cpu.InterruptsEnabled {
+		t.Errorf("Expected interrupts to be enabled again after IRET")
+	}
+
+	if cpu.StackDepth() != 0 {
+		t.Errorf("Expected an empty stack, got depth %d", cpu.StackDepth())
+	}
+
+	if cpu.PendingIRQs() != 0 {
+		t.Errorf("Expected no pending interrupts, got %08b", cpu.PendingIRQs())
+	}
+}
+
+func TestInterruptEntry(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R3 0",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.Reset()
+	cpu.InterruptsEnabled = true
+	cpu.Flags.Carry = 1
+	cpu.SetVector(mem, 3, 0x1000)
+	cpu.SetVector(mem, 5, 0x2000)
+	mem.Data[0x1000] = uint8(OP_HLT_NONE)
+	// The handler is outside the loaded code
+	mem.Protect()
+
+	cpu.RaiseIRQ(5)
+	cpu.RaiseIRQ(3)
+
+	instruction, err := cpu.Step(mem)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if instruction.Address != 0x1000 {
+		t.Errorf("Expected the lowest line to be serviced first, got handler at %d", instruction.Address)
+	}
+
+	if cpu.InterruptsEnabled {
+		t.Errorf("Expected interrupts to be disabled inside the handler")
+	}
+
+	if cpu.PendingIRQs() != 1<<5 {
+		t.Errorf("Expected line 5 to still be pending, got %08b", cpu.PendingIRQs())
+	}
+
+	// Flags on top, then the return address high byte first
+	if mem.Data[StackTop-2] != interruptsEnabledBit|1<<4 {
+		t.Errorf("Expected the saved flags to be %08b, got %08b", interruptsEnabledBit|1<<4, mem.Data[StackTop-2])
+	}
+
+	if mem.Data[StackTop] != 0 || mem.Data[StackTop-1] != CodeMemoryStart {
+		t.Errorf("Expected the return address %d to be saved, got %d %d", CodeMemoryStart, mem.Data[StackTop], mem.Data[StackTop-1])
+	}
+}
+
+func TestInterruptMasking(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R3 0",
+		"DI",
+		"LOAD R3 0",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.Reset()
+	cpu.SetVector(mem, 0, 0x1000)
+	cpu.SetVector(mem, 2, 0x1000)
+	cpu.InterruptsEnabled = true
+	cpu.InterruptMask = 1 << 2
+	cpu.RaiseIRQ(2)
+
+	if _, err := cpu.Step(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.ProgramCounter != CodeMemoryStart+3 {
+		t.Errorf("Expected a masked line not to be serviced, got PC %d", cpu.ProgramCounter)
+	}
+
+	// DI runs before the next check, so line 0 waits too
+	if _, err := cpu.Step(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	cpu.RaiseIRQ(0)
+	if _, err := cpu.Run(mem, 0); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.PendingIRQs() != 1<<2|1 {
+		t.Errorf("Expected both lines to stay pending, got %08b", cpu.PendingIRQs())
+	}
+
+	cpu.RaiseIRQ(InterruptLineCount)
+	if cpu.PendingIRQs() != 1<<2|1 {
+		t.Errorf("Expected an out of range line to be ignored, got %08b", cpu.PendingIRQs())
+	}
+
+	cpu.Reset()
+	if cpu.PendingIRQs() != 0 || cpu.InterruptMask != 0 || cpu.InterruptsEnabled {
+		t.Errorf("Expected Reset to clear the interrupt state")
+	}
+}
+
+func TestInterruptMaskRegister(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"STORE 0xFEE2 <handler",
+		"STORE 0xFEE3 >handler",
+		// Mask line 1 before enabling interrupts
+		"LOAD R0 2",
+		"OUT 0x40 R0",
+		"EI",
+		"IN R1 0x41",
+		"LOAD R0 0",
+		"OUT 0x40 R0",
+		"HLT",
+		"handler:",
+		"LOAD R2 9",
+		"IRET",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+	cpu.MapPorts(SystemPort, SystemRegistersSize, cpu.SystemRegisters())
+
+	cpu.RaiseIRQ(1)
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.Registers[1] != 2 {
+		t.Errorf("Expected line 1 to stay pending while masked, got %08b", cpu.Registers[1])
+	}
+	if cpu.Registers[2] != 9 {
+		t.Errorf("Expected the handler to run once the line was unmasked")
+	}
+	if cpu.PendingIRQs() != 0 {
+		t.Errorf("Expected no pending interrupts, got %08b", cpu.PendingIRQs())
+	}
+
+	// Writing the pending register clears lines without servicing them
+	registers := cpu.SystemRegisters()
+	cpu.RaiseIRQ(3)
+	cpu.RaiseIRQ(5)
+	registers.Write(systemPendingRegister, 1<<3)
+	if cpu.PendingIRQs() != 1<<5 {
+		t.Errorf("Expected only line 5 to stay pending, got %08b", cpu.PendingIRQs())
+	}
+}
+
+func TestUnhandledInterrupt(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"EI",
+		"LOAD R3 0",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.RaiseIRQ(4)
+	err = cpu.Execute(mem)
+	if !errors.Is(err, UNHANDLED_INTERRUPT) {
+		t.Fatalf("Expected an unhandled interrupt error, got %v", err)
+	}
+
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.ProgramCounter != CodeMemoryStart+1 {
+		t.Errorf("Expected the error at the interrupted instruction, got %v", err)
+	}
+}
+
+func TestRaiseIRQConcurrently(t *testing.T) {
+	cpu := NewCPU(DefaultLayout)
+
+	var wg sync.WaitGroup
+	for line := range uint8(InterruptLineCount) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cpu.RaiseIRQ(line)
+		}()
+	}
+	wg.Wait()
+
+	if cpu.PendingIRQs() != 0xFF {
+		t.Errorf("Expected every line to be pending, got %08b", cpu.PendingIRQs())
+	}
+}
+
+func TestIretUnderflow(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"PUSH 1",
+		"IRET",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	if err := cpu.Execute(mem); !errors.Is(err, STACK_UNDERFLOW) {
+		t.Errorf("Expected a stack underflow, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package cpu
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// SetInput sets the stream READ and READS take input from
+func (c *CPU) SetInput(r io.Reader) {
+	c.inputSource = &inputReader{r: r}
+	c.input = bufio.NewReader(c.inputSource)
+	c.unreadLine = nil
+}
+
+// inputReader reads the input stream. During ExecuteContext each read runs in
+// the background, so a program waiting for input still stops when the context
+// is cancelled or the time limit is hit. A read that is given up on carries on,
+// and the next read returns its data, so no input is lost.
+type inputReader struct {
+	r io.Reader
+
+	// ctx and deadline are only set during ExecuteContext
+	ctx      context.Context
+	deadline time.Time
+
+	// pending delivers the result of the read in progress, if any, and
+	// leftover holds data it returned that didn't fit the caller's buffer
+	pending  chan inputChunk
+	leftover []byte
+}
+
+type inputChunk struct {
+	data []byte
+	err  error
+}
+
+func (r *inputReader) Read(p []byte) (int, error) {
+	if len(r.leftover) > 0 {
+		n := copy(p, r.leftover)
+		r.leftover = r.leftover[n:]
+		return n, nil
+	}
+	if r.pending == nil {
+		if r.ctx == nil {
+			return r.r.Read(p)
+		}
+		r.pending = make(chan inputChunk, 1)
+		go func(pending chan<- inputChunk, buf []byte) {
+			n, err := r.r.Read(buf)
+			pending <- inputChunk{buf[:n], err}
+		}(r.pending, make([]byte, len(p)))
+	}
+
+	var done <-chan struct{}
+	var timeout <-chan time.Time
+	if r.ctx != nil {
+		done = r.ctx.Done()
+		if !r.deadline.IsZero() {
+			timer := time.NewTimer(time.Until(r.deadline))
+			defer timer.Stop()
+			timeout = timer.C
+		}
+	}
+
+	select {
+	case chunk := <-r.pending:
+		r.pending = nil
+		n := copy(p, chunk.data)
+		r.leftover = chunk.data[n:]
+		return n, chunk.err
+	case <-done:
+		return 0, r.ctx.Err()
+	case <-timeout:
+		return 0, TIME_LIMIT_EXCEEDED
+	}
+}
+
+// inputError reports a failed read as an IO_ERROR, except for a read that
+// ExecuteContext gave up on, which reports why
+func inputError(err error) error {
+	if errors.Is(err, TIME_LIMIT_EXCEEDED) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", IO_ERROR, err)
+}
+
+// SetOutput sets the stream the PRINT instructions write to
+func (c *CPU) SetOutput(w io.Writer) {
+	c.output = w
+}
+
+// readByte reads a single byte of input, returning 0 at end of input
+func (c *CPU) readByte() (uint8, error) {
+	value, err := c.input.ReadByte()
+	if errors.Is(err, io.EOF) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, inputError(err)
+	}
+	return value, nil
+}
+
+// readLine reads a line of input without its line ending. At end of input the
+// line is empty.
+func (c *CPU) readLine() ([]byte, error) {
+	if line := c.unreadLine; line != nil {
+		c.unreadLine = nil
+		return line, nil
+	}
+	line, err := c.input.ReadBytes('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, inputError(err)
+	}
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	return line, nil
+}
@@ -0,0 +1,92 @@
+package cpu
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintOutput(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"PRINT 42",
+		"LOAD R0 7",
+		"PRINT R0",
+		"STORE 0 h",
+		"STORE 1 i",
+		"STORE 2 0",
+		"PRINTS 0",
+		"PRINTC 33",
+		"LOAD R1 10",
+		"PRINTC R1",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	var output bytes.Buffer
+	cpu.SetOutput(&output)
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if output.String() != "42\n7\nhi!\n" {
+		t.Errorf("Expected output %q, got %q", "42\n7\nhi!\n", output.String())
+	}
+}
+
+func TestReadR(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"READ R0",
+		"READ R1",
+		"READ R2",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.SetInput(strings.NewReader("ab"))
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.Registers[0] != 'a' || cpu.Registers[1] != 'b' {
+		t.Errorf("Expected registers to hold 'a' and 'b', got %d and %d", cpu.Registers[0], cpu.Registers[1])
+	}
+
+	if cpu.Registers[2] != 0 {
+		t.Errorf("Expected register 2 to be 0 at end of input, got %d", cpu.Registers[2])
+	}
+}
+
+func TestReadsA(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"READS 0",
+		"READS 10",
+		"PRINTS 10",
+		"PRINTS 0",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	var output bytes.Buffer
+	cpu.SetInput(strings.NewReader("hello\r\nworld\n"))
+	cpu.SetOutput(&output)
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if output.String() != "worldhello" {
+		t.Errorf("Expected output %q, got %q", "worldhello", output.String())
+	}
+
+	if mem.Data[5] != 0 {
+		t.Errorf("Expected the line to be null-terminated, got %d", mem.Data[5])
+	}
+}
@@ -0,0 +1,112 @@
+package cpu
+
+import "fmt"
+
+// Layout describes how memory is divided up. Stored memory (data) starts at 0,
+// code is loaded at the code origin and execution starts there, and the stack
+// grows down from the stack top with the interrupt vector table just below it.
+// A layout with a bank size also has a bank window between the code and the
+// vector table, where a Banks controller is mapped.
+type Layout struct {
+	DataSize   int    // Bytes of stored memory from address 0
+	CodeOrigin uint16 // Where code is loaded and execution starts
+	TotalSize  int    // Bytes of memory, at most TotalMemorySize
+	StackTop   uint16 // The first address pushed to
+	StackSize  int    // Bytes the stack may grow to
+	BankWindow uint16 // Where the bank window starts
+	BankSize   int    // Bytes in the bank window and each bank, 0 for no banks
+}
+
+// DefaultLayout is the layout programs have always had: 55 bytes of stored
+// memory followed by code, and a 256 byte stack at the top of 64 KiB.
+var DefaultLayout = Layout{
+	DataSize:   StoredMemorySize,
+	CodeOrigin: CodeMemoryStart,
+	TotalSize:  TotalMemorySize,
+	StackTop:   StackTop,
+	StackSize:  StackSize,
+}
+
+// StackLimit returns the lowest address the stack may grow to
+func (l Layout) StackLimit() uint16 {
+	return uint16(int(l.StackTop) - l.StackSize + 1)
+}
+
+// VectorBase returns where the interrupt vector table starts, just below the
+// stack
+func (l Layout) VectorBase() uint16 {
+	return uint16(int(l.StackLimit()) - VectorCount*2)
+}
+
+// Banked reports whether the layout has a bank window
+func (l Layout) Banked() bool {
+	return l.BankSize > 0
+}
+
+// CodeSpace returns how many bytes of code fit at the code origin, up to the
+// bank window if there is one and the vector table otherwise
+func (l Layout) CodeSpace() int {
+	if l.Banked() {
+		return int(l.BankWindow) - int(l.CodeOrigin)
+	}
+	return int(l.VectorBase()) - int(l.CodeOrigin)
+}
+
+// Validate checks that the parts of the layout fit in memory in order without
+// overlapping
+func (l Layout) Validate() error {
+	switch {
+	case l.TotalSize <= 0 || l.TotalSize > TotalMemorySize:
+		return fmt.Errorf("total size %d must be from 1 to %d", l.TotalSize, TotalMemorySize)
+	case l.DataSize < 0 || l.DataSize > int(l.CodeOrigin):
+		return fmt.Errorf("data size %d must not run past the code origin %d", l.DataSize, l.CodeOrigin)
+	case int(l.StackTop) >= l.TotalSize:
+		return fmt.Errorf("stack top %d must be inside the %d bytes of memory", l.StackTop, l.TotalSize)
+	case l.StackSize <= 0 || int(l.StackTop)-l.StackSize+1-VectorCount*2 <= int(l.CodeOrigin):
+		return fmt.Errorf("stack of %d bytes and vector table must fit between the code origin %d and stack top %d", l.StackSize, l.CodeOrigin, l.StackTop)
+	case l.BankSize < 0:
+		return fmt.Errorf("bank size %d must not be negative", l.BankSize)
+	case l.Banked() && (l.BankWindow < l.CodeOrigin || int(l.BankWindow)+l.BankSize > int(l.VectorBase())):
+		return fmt.Errorf("bank window %d+%d must fit between the code origin %d and vector table at %d", l.BankWindow, l.BankSize, l.CodeOrigin, l.VectorBase())
+	}
+	return nil
+}
+
+// Regions returns the protection regions for a program with codeSize bytes of
+// code: stored memory is data, the code can be read and executed but not
+// written, and the rest of memory, the vector table and the stack are data.
+// The bank window holds both code and data, so it allows everything.
+func (l Layout) Regions(codeSize int) []Region {
+	codeEnd := int(l.CodeOrigin) + codeSize
+	vectorBase, stackLimit := int(l.VectorBase()), int(l.StackLimit())
+
+	regions := []Region{
+		{"data", 0, l.DataSize, PermRead | PermWrite},
+		{"free", uint16(l.DataSize), int(l.CodeOrigin) - l.DataSize, PermRead | PermWrite},
+		{"code", l.CodeOrigin, codeSize, PermRead | PermExecute},
+	}
+	heapStart := codeEnd
+	if l.Banked() {
+		windowEnd := int(l.BankWindow) + l.BankSize
+		regions = append(regions,
+			Region{"heap", uint16(codeEnd), int(l.BankWindow) - codeEnd, PermRead | PermWrite},
+			Region{"banks", l.BankWindow, l.BankSize, PermRead | PermWrite | PermExecute},
+		)
+		heapStart = windowEnd
+	}
+	regions = append(regions,
+		Region{"heap", uint16(heapStart), vectorBase - heapStart, PermRead | PermWrite},
+		Region{"vectors", uint16(vectorBase), VectorCount * 2, PermRead | PermWrite},
+		Region{"stack", uint16(stackLimit), l.StackSize, PermRead | PermWrite},
+		Region{"free", l.StackTop + 1, l.TotalSize - int(l.StackTop) - 1, PermRead | PermWrite},
+	)
+
+	// Leave out parts of memory the layout has no room for
+	var nonEmpty []Region
+	for _, r := range regions {
+		if r.Size > 0 {
+			nonEmpty = append(nonEmpty, r)
+		}
+	}
+	return nonEmpty
+}
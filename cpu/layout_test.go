@@ -0,0 +1,128 @@
+package cpu
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCustomLayout(t *testing.T) {
+	layout := Layout{
+		DataSize:   16,
+		CodeOrigin: 0x100,
+		TotalSize:  0x1000,
+		StackTop:   0xFFF,
+		StackSize:  64,
+	}
+	if err := layout.Validate(); err != nil {
+		t.Fatalf("Unexpected invalid layout: %s", err)
+	}
+
+	code, err := NewAssembler([]string{
+		"CALL store",
+		"HLT",
+		"store:",
+		"STORE 15 7",
+		"RET",
+	}, layout).Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	// The label is at the code origin plus the 4 bytes before it
+	if code[1] != 0x04 || code[2] != 0x01 {
+		t.Errorf("Expected the label to be at 0x104, got %#02x%02x", code[2], code[1])
+	}
+
+	cpu := NewCPU(layout)
+	mem := NewMemory(layout)
+	mem.LoadCode(code)
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if mem.Data[15] != 7 {
+		t.Errorf("Expected the store to stored memory, got %d", mem.Data[15])
+	}
+
+	// The return address went on the stack at the top of the smaller memory
+	if mem.Data[0xFFF] != 0x01 || mem.Data[0xFFE] != 0x03 {
+		t.Errorf("Expected the return address 0x103 on the stack, got %#02x%02x", mem.Data[0xFFF], mem.Data[0xFFE])
+	}
+
+	if cpu.StackLimit != 0xFC0 || cpu.VectorBase != 0xFA0 {
+		t.Errorf("Expected the stack limit and vectors from the layout, got %#x and %#x", cpu.StackLimit, cpu.VectorBase)
+	}
+
+	if _, err := mem.Read(0x1000); !errors.Is(err, MEMORY_OUT_OF_BOUNDS) {
+		t.Errorf("Expected reading past the end of memory to be out of bounds, got %v", err)
+	}
+
+	if err := mem.WriteStoredMemory(16, 1); !errors.Is(err, MEMORY_OUT_OF_BOUNDS) {
+		t.Errorf("Expected stored memory to end at the data size, got %v", err)
+	}
+
+	expected := []string{"data", "free", "code", "heap", "vectors", "stack"}
+	regions := mem.Regions()
+	if len(regions) != len(expected) {
+		t.Fatalf("Expected regions %v, got %v", expected, regions)
+	}
+	for i, name := range expected {
+		if regions[i].Name != name {
+			t.Errorf("Expected region %d to be %s, got %s", i, name, regions[i].Name)
+		}
+	}
+}
+
+func TestLayoutValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Layout)
+	}{
+		{"too much memory", func(l *Layout) { l.TotalSize = TotalMemorySize + 1 }},
+		{"data past the code", func(l *Layout) { l.DataSize = int(l.CodeOrigin) + 1 }},
+		{"stack outside memory", func(l *Layout) { l.TotalSize = int(l.StackTop) }},
+		{"no stack", func(l *Layout) { l.StackSize = 0 }},
+		{"stack over the code", func(l *Layout) { l.CodeOrigin = l.VectorBase() }},
+		{"negative bank size", func(l *Layout) { l.BankSize = -1 }},
+		{"bank window below the code", func(l *Layout) { l.BankWindow, l.BankSize = 0, 0x100 }},
+		{"bank window over the vectors", func(l *Layout) { l.BankWindow, l.BankSize = l.VectorBase()-0x10, 0x100 }},
+	}
+
+	if err := DefaultLayout.Validate(); err != nil {
+		t.Errorf("Expected the default layout to be valid, got %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout := DefaultLayout
+			tt.modify(&layout)
+			if err := layout.Validate(); err == nil {
+				t.Errorf("Expected %+v to be invalid", layout)
+			}
+		})
+	}
+}
+
+func TestBankedLayoutRegions(t *testing.T) {
+	regions := bankedLayout.Regions(10)
+
+	expected := []string{"data", "code", "heap", "banks", "heap", "vectors", "stack"}
+	if len(regions) != len(expected) {
+		t.Fatalf("Expected regions %v, got %v", expected, regions)
+	}
+	for i, name := range expected {
+		if regions[i].Name != name {
+			t.Errorf("Expected region %d to be %s, got %s", i, name, regions[i].Name)
+		}
+	}
+
+	banks := regions[3]
+	if banks.Start != 0x8000 || banks.Size != 0x100 || banks.Permissions != PermRead|PermWrite|PermExecute {
+		t.Errorf("Expected the bank window to allow everything, got %+v", banks)
+	}
+
+	if bankedLayout.CodeSpace() != 0x8000-CodeMemoryStart {
+		t.Errorf("Expected code to fit up to the bank window, got %d bytes", bankedLayout.CodeSpace())
+	}
+}
@@ -0,0 +1,105 @@
+package cpu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// limitCheckInterval is how many instructions run between checks of the
+// context and the wall clock
+const limitCheckInterval = 256
+
+// Limits bounds a single ExecuteContext run. A zero field means no limit.
+type Limits struct {
+	MaxInstructions int
+	MaxDuration     time.Duration
+	MaxOutputBytes  int
+	MaxStackDepth   int
+}
+
+// ExecuteContext runs the program in memory from the start of code memory like
+// Execute, but stops with an error when ctx is cancelled or a limit is hit, even
+// while waiting for input. Cancellation returns ctx.Err(); each limit has its
+// own RuntimeErrorType. A breakpoint or watchpoint returns ErrStopped, since the
+// program didn't halt.
+func (c *CPU) ExecuteContext(ctx context.Context, bus Bus, limits Limits) error {
+	c.ProgramCounter = c.layout.CodeOrigin
+	c.Halted = false
+	c.limits = limits
+	c.outputBytes = 0
+
+	var deadline time.Time
+	if limits.MaxDuration > 0 {
+		deadline = time.Now().Add(limits.MaxDuration)
+	}
+	input := c.inputSource
+	input.ctx, input.deadline = ctx, deadline
+	defer func() {
+		c.limits = Limits{}
+		input.ctx, input.deadline = nil, time.Time{}
+	}()
+
+	steps := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return c.errorAtProgramCounter(bus, TIME_LIMIT_EXCEEDED)
+		}
+
+		budget := limitCheckInterval
+		if limits.MaxInstructions > 0 {
+			if steps >= limits.MaxInstructions {
+				return c.errorAtProgramCounter(bus, INSTRUCTION_LIMIT_EXCEEDED)
+			}
+			budget = min(budget, limits.MaxInstructions-steps)
+		}
+
+		stop, err := c.Run(bus, budget)
+		steps += stop.Steps
+		if err != nil {
+			// A read cancelled while waiting for input
+			if ctxErr := ctx.Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+				return ctxErr
+			}
+			return err
+		}
+		switch stop.Kind {
+		case STOP_HALT:
+			return nil
+		case STOP_BREAKPOINT:
+			return fmt.Errorf("%w at breakpoint %d at address %d", ErrStopped, stop.Breakpoint.ID, stop.Breakpoint.Address)
+		case STOP_WATCHPOINT:
+			return fmt.Errorf("%w at watchpoint %d on address %d", ErrStopped, stop.Watch.Watchpoint.ID, stop.Watch.Watchpoint.Address)
+		}
+	}
+}
+
+// errorAtProgramCounter reports a fault raised between instructions, such as a
+// limit being hit, against the instruction at the program counter
+func (c *CPU) errorAtProgramCounter(bus Bus, err error) *RuntimeError {
+	opcode, _ := readCode(bus, c.ProgramCounter)
+	return c.newRuntimeError(c.ProgramCounter, Opcode(opcode), nil, err)
+}
+
+// print writes program output, enforcing the output limit. Output up to the
+// limit is still written before the error is returned.
+func (c *CPU) print(s string) error {
+	if c.limits.MaxOutputBytes > 0 && c.outputBytes+len(s) > c.limits.MaxOutputBytes {
+		allowed := c.limits.MaxOutputBytes - c.outputBytes
+		io.WriteString(c.output, s[:allowed])
+		c.outputBytes += allowed
+		return OUTPUT_LIMIT_EXCEEDED
+	}
+
+	n, err := io.WriteString(c.output, s)
+	c.outputBytes += n
+	if err != nil {
+		return fmt.Errorf("%w: %v", IO_ERROR, err)
+	}
+	return nil
+}
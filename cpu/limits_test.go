@@ -0,0 +1,185 @@
+package cpu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+var infiniteLoop = []string{
+	"loop:",
+	"INC R0",
+	"JMP loop",
+}
+
+func TestExecuteContextInstructionLimit(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem(infiniteLoop)
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	err = cpu.ExecuteContext(context.Background(), mem, Limits{MaxInstructions: 1000})
+	if !errors.Is(err, INSTRUCTION_LIMIT_EXCEEDED) {
+		t.Fatalf("Expected instruction limit error, got %v", err)
+	}
+
+	if cpu.Registers[0] != uint8(500%256) {
+		t.Errorf("Expected 500 increments, register 0 is %d", cpu.Registers[0])
+	}
+}
+
+func TestExecuteContextTimeLimit(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem(infiniteLoop)
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	err = cpu.ExecuteContext(context.Background(), mem, Limits{MaxDuration: 10 * time.Millisecond})
+	if !errors.Is(err, TIME_LIMIT_EXCEEDED) {
+		t.Errorf("Expected time limit error, got %v", err)
+	}
+}
+
+func TestExecuteContextCancel(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem(infiniteLoop)
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = cpu.ExecuteContext(ctx, mem, Limits{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context deadline error, got %v", err)
+	}
+}
+
+func TestExecuteContextWaitingForInput(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"READ R0",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+	input, writer := io.Pipe()
+	cpu.SetInput(input)
+
+	err = cpu.ExecuteContext(context.Background(), mem, Limits{MaxDuration: 10 * time.Millisecond})
+	if !errors.Is(err, TIME_LIMIT_EXCEEDED) {
+		t.Fatalf("Expected time limit error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := cpu.ExecuteContext(ctx, mem, Limits{}); err != context.Canceled {
+		t.Fatalf("Expected the context's error, got %v", err)
+	}
+
+	// The read that was given up on still delivers its input
+	go writer.Write([]byte("x"))
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if cpu.Registers[0] != 'x' {
+		t.Errorf("Expected to read the input, got %q", cpu.Registers[0])
+	}
+}
+
+func TestExecuteContextOutputLimit(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"PRINT 1",
+		"PRINT 2",
+		"PRINT 3",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	var output bytes.Buffer
+	cpu.SetOutput(&output)
+
+	err = cpu.ExecuteContext(context.Background(), mem, Limits{MaxOutputBytes: 5})
+	if !errors.Is(err, OUTPUT_LIMIT_EXCEEDED) {
+		t.Fatalf("Expected output limit error, got %v", err)
+	}
+
+	if output.String() != "1\n2\n3" {
+		t.Errorf("Expected output to be cut off at 5 bytes, got %q", output.String())
+	}
+
+	var runtimeErr *RuntimeError
+	if errors.As(err, &runtimeErr) && runtimeErr.ProgramCounter != CodeMemoryStart+4 {
+		t.Errorf("Expected the third PRINT to fault, got address %d", runtimeErr.ProgramCounter)
+	}
+}
+
+func TestExecuteContextStackLimit(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"loop:",
+		"PUSH 1",
+		"JMP loop",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	err = cpu.ExecuteContext(context.Background(), mem, Limits{MaxStackDepth: 16})
+	if !errors.Is(err, STACK_LIMIT_EXCEEDED) {
+		t.Fatalf("Expected stack limit error, got %v", err)
+	}
+
+	if cpu.StackDepth() != 16 {
+		t.Errorf("Expected stack depth to be 16, got %d", cpu.StackDepth())
+	}
+}
+
+func TestExecuteContextHalts(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R0 42",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	limits := Limits{MaxInstructions: 2, MaxDuration: time.Second, MaxStackDepth: 1}
+	if err := cpu.ExecuteContext(context.Background(), mem, limits); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	if cpu.Registers[0] != 42 {
+		t.Errorf("Expected register 0 to be 42, got %d", cpu.Registers[0])
+	}
+}
+
+func TestExecuteContextDebugStop(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"STORE 5 1",
+		"LOAD R0 42",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	id := cpu.AddBreakpoint(CodeMemoryStart + 4)
+	err = cpu.ExecuteContext(context.Background(), mem, Limits{MaxInstructions: 100})
+	if !errors.Is(err, ErrStopped) {
+		t.Fatalf("Expected a breakpoint to stop the run with an error, got %v", err)
+	}
+	if cpu.Halted || cpu.Registers[0] != 0 {
+		t.Errorf("Expected to stop before the LOAD, got R0 %d", cpu.Registers[0])
+	}
+
+	cpu.RemoveBreakpoint(id)
+	cpu.AddWatchpoint(5, WATCH_WRITE)
+	if err := cpu.ExecuteContext(context.Background(), mem, Limits{}); !errors.Is(err, ErrStopped) {
+		t.Errorf("Expected a watchpoint to stop the run with an error, got %v", err)
+	}
+}
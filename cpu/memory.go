@@ -1,52 +1,79 @@
 package cpu
 
+import "fmt"
+
+// Sizes in the default layout. TotalMemorySize is also the size of the address
+// space, so it is the most memory any layout can have.
 const (
 	StoredMemorySize = 55
-	TotalMemorySize  = 256
+	TotalMemorySize  = 65536
 	CodeMemoryStart  = StoredMemorySize
 )
 
 type Memory struct {
-	Data [TotalMemorySize]uint8
+	Data   []uint8
+	Layout Layout
+
+	// SelfModifying lets programs write to code and execute data, such as
+	// code they have loaded, see protection.go
+	SelfModifying bool
+	regions       []Region
 }
 
-func NewMemory() *Memory {
-	return &Memory{}
+// NewMemory creates memory of the size given by layout, which is assumed to be
+// valid
+func NewMemory(layout Layout) *Memory {
+	return &Memory{
+		Data:   make([]uint8, layout.TotalSize),
+		Layout: layout,
+	}
 }
 
-func (m *Memory) Read(address uint16) uint8 {
-	if address >= TotalMemorySize {
-		panic("Memory read out of bounds")
+func (m *Memory) Read(address uint16) (uint8, error) {
+	if int(address) >= len(m.Data) {
+		return 0, fmt.Errorf("%w: read at address %d", MEMORY_OUT_OF_BOUNDS, address)
 	}
-	return m.Data[address]
+	if err := m.check(address, PermRead, "read"); err != nil {
+		return 0, err
+	}
+	return m.Data[address], nil
 }
 
-func (m *Memory) Write(address uint16, value uint8) {
-	if address >= TotalMemorySize {
-		panic("Memory write out of bounds")
+func (m *Memory) Write(address uint16, value uint8) error {
+	if int(address) >= len(m.Data) {
+		return fmt.Errorf("%w: write at address %d", MEMORY_OUT_OF_BOUNDS, address)
+	}
+	if err := m.check(address, PermWrite, "write"); err != nil {
+		return err
 	}
 	m.Data[address] = value
+	return nil
 }
 
-func (m *Memory) ReadStoredMemory(address uint16) uint8 {
-	if address >= StoredMemorySize {
-		panic("Stored memory read out of bounds")
+func (m *Memory) ReadStoredMemory(address uint16) (uint8, error) {
+	if int(address) >= m.Layout.DataSize {
+		return 0, fmt.Errorf("%w: stored memory read at address %d", MEMORY_OUT_OF_BOUNDS, address)
 	}
-	return m.Data[address]
+	return m.Data[address], nil
 }
 
-func (m *Memory) WriteStoredMemory(address uint16, value uint8) {
-	if address >= StoredMemorySize {
-		panic("Stored memory write out of bounds")
+func (m *Memory) WriteStoredMemory(address uint16, value uint8) error {
+	if int(address) >= m.Layout.DataSize {
+		return fmt.Errorf("%w: stored memory write at address %d", MEMORY_OUT_OF_BOUNDS, address)
 	}
 	m.Data[address] = value
+	return nil
 }
 
-func (m *Memory) LoadCode(code []uint8) {
-	if len(code) > (TotalMemorySize - CodeMemoryStart) {
-		panic("Code exceeds available memory space")
+// LoadCode copies code to the code origin and protects memory with the
+// layout's regions
+func (m *Memory) LoadCode(code []uint8) error {
+	if len(code) > m.Layout.CodeSpace() {
+		return fmt.Errorf("%w: %d bytes of code exceed the %d bytes of code space", MEMORY_OUT_OF_BOUNDS, len(code), m.Layout.CodeSpace())
 	}
-	for i, b := range code {
-		m.Write(uint16(CodeMemoryStart+i), b)
+	copy(m.Data[m.Layout.CodeOrigin:], code)
+	if len(code) > 0 {
+		return m.Protect(m.Layout.Regions(len(code))...)
 	}
+	return nil
 }
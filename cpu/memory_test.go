@@ -1,22 +1,42 @@
 package cpu
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestMemoryReadWrite(t *testing.T) {
-	mem := NewMemory()
+	mem := NewMemory(DefaultLayout)
 
-	mem.Write(10, 42)
-	value := mem.Read(10)
+	if err := mem.Write(10, 42); err != nil {
+		t.Fatalf("Unexpected error writing memory: %s", err)
+	}
+	value, err := mem.Read(10)
+	if err != nil {
+		t.Fatalf("Unexpected error reading memory: %s", err)
+	}
 
 	if value != 42 {
 		t.Errorf("Expected memory at address 10 to be 42, got %d", value)
 	}
 
-	defer func() {
-		if r := recover(); r == nil {
-			t.Errorf("Expected panic for out of bounds memory read")
-		}
-	}()
+	if err := mem.Write(0xFFFF, 55); err != nil {
+		t.Errorf("Expected the last address to be writable, got %v", err)
+	}
+
+	if _, err := mem.ReadStoredMemory(StoredMemorySize); !errors.Is(err, MEMORY_OUT_OF_BOUNDS) {
+		t.Errorf("Expected out of bounds error for stored memory read, got %v", err)
+	}
+}
+
+func TestLoadCodeTooLarge(t *testing.T) {
+	mem := NewMemory(DefaultLayout)
 
-	mem.Write(300, 55)
+	if err := mem.LoadCode(make([]uint8, DefaultLayout.CodeSpace()+1)); !errors.Is(err, MEMORY_OUT_OF_BOUNDS) {
+		t.Errorf("Expected an out of bounds error for code larger than the code space, got %v", err)
+	}
+
+	if err := mem.LoadCode(make([]uint8, DefaultLayout.CodeSpace())); err != nil {
+		t.Errorf("Expected code filling the code space to load, got %v", err)
+	}
 }
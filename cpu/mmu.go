@@ -0,0 +1,226 @@
+package cpu
+
+import "fmt"
+
+// Pages are 256 bytes, so the high byte of an address is its page number and
+// the low byte is the offset into the page. The page table has a two byte
+// entry for each page: the physical frame number, then the page flags.
+const (
+	PageSize      = 256
+	PageCount     = TotalMemorySize / PageSize
+	PageTableSize = PageCount * 2
+)
+
+// Page table entry flags
+const (
+	PagePresent  uint8 = 1 << iota // The page is mapped to the frame
+	PageWritable                   // The page may be written
+	PageUser                       // The page may be accessed in user mode
+)
+
+// Fault cause bits, describing the access that raised a page fault
+const (
+	FaultPresent uint8 = 1 << iota // The page was present, so its flags refused the access
+	FaultWrite                     // The access was a write
+	FaultUser                      // The access was made in user mode
+	FaultFetch                     // The access was an instruction fetch
+)
+
+// Ports of the MMU registers
+const (
+	MMUPort          = 0x34
+	MMURegistersSize = 6
+)
+
+// MMU registers, as offsets from MMUPort
+const (
+	mmuControlRegister    = iota // Bit 0 enables translation
+	mmuTableLowRegister          // Page table base, low byte
+	mmuTableHighRegister         // Page table base, high byte
+	mmuFaultLowRegister          // Virtual address of the last page fault, low byte
+	mmuFaultHighRegister         // Virtual address of the last page fault, high byte
+	mmuFaultCauseRegister        // Fault cause bits of the last page fault
+)
+
+// MMU is a paging memory management unit. Placed between the CPU and the bus,
+// it translates the virtual addresses the CPU uses into physical addresses on
+// the bus through a page table in physical memory. Until it is enabled it
+// passes addresses through unchanged.
+//
+// An access the page table doesn't allow is a page fault. The MMU records the
+// virtual address and cause, and the CPU traps to PageFaultVector with the
+// faulting instruction undone, so the handler can map the page and return to
+// run the instruction again.
+type MMU struct {
+	Enabled       bool
+	PageTableBase uint16
+
+	// UserMode is set while the CPU runs in user mode, when pages without
+	// PageUser may not be accessed. The CPU keeps it up to date.
+	UserMode bool
+
+	FaultAddress uint16
+	FaultCause   uint8
+
+	bus Bus
+}
+
+// NewMMU creates a disabled MMU in front of the physical bus
+func NewMMU(bus Bus) *MMU {
+	return &MMU{bus: bus}
+}
+
+// SetUserMode makes the MMU a ModeTracker
+func (m *MMU) SetUserMode(user bool) {
+	m.UserMode = user
+}
+
+// Translate returns the physical address of a virtual address for an access
+// with the given fault cause bits, or a page fault
+func (m *MMU) Translate(address uint16, access uint8) (uint16, error) {
+	if !m.Enabled {
+		return address, nil
+	}
+	if m.UserMode {
+		access |= FaultUser
+	}
+
+	if int(m.PageTableBase)+PageTableSize > TotalMemorySize {
+		return 0, fmt.Errorf("%w: page table at %d runs past the end of memory", BUS_ERROR, m.PageTableBase)
+	}
+	entry := m.PageTableBase + uint16(address>>8)*2
+	frame, err := m.bus.Read(entry)
+	if err != nil {
+		return 0, err
+	}
+	flags, err := m.bus.Read(entry + 1)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case flags&PagePresent == 0:
+		return 0, m.fault(address, access, "not present")
+	case access&FaultWrite != 0 && flags&PageWritable == 0:
+		return 0, m.fault(address, access|FaultPresent, "not writable")
+	case m.UserMode && flags&PageUser == 0:
+		return 0, m.fault(address, access|FaultPresent, "not accessible in user mode")
+	}
+	return uint16(frame)<<8 | address&0xFF, nil
+}
+
+// fault records a page fault and returns it as an error
+func (m *MMU) fault(address uint16, cause uint8, reason string) error {
+	m.FaultAddress = address
+	m.FaultCause = cause
+
+	access := "read"
+	if cause&FaultWrite != 0 {
+		access = "write"
+	} else if cause&FaultFetch != 0 {
+		access = "fetch"
+	}
+	return fmt.Errorf("%w: %s at virtual address %d, page %s", PAGE_FAULT, access, address, reason)
+}
+
+// checkWritable translates the n addresses from address for a write when the
+// bus is an MMU. Instructions that write several bytes check them all first,
+// so a page fault leaves memory as it was and the instruction can run again.
+func checkWritable(bus Bus, address uint16, n int) error {
+	mmu, ok := bus.(*MMU)
+	if !ok {
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		// Pages are translated as a whole, so one address per page will do
+		if next := address + uint16(i); i == 0 || next%PageSize == 0 {
+			if _, err := mmu.Translate(next, FaultWrite); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *MMU) Read(address uint16) (uint8, error) {
+	physical, err := m.Translate(address, 0)
+	if err != nil {
+		return 0, err
+	}
+	return m.bus.Read(physical)
+}
+
+func (m *MMU) Write(address uint16, value uint8) error {
+	physical, err := m.Translate(address, FaultWrite)
+	if err != nil {
+		return err
+	}
+	return m.bus.Write(physical, value)
+}
+
+// Fetch translates an instruction fetch, passing it on as a fetch if the bus
+// is a Fetcher
+func (m *MMU) Fetch(address uint16) (uint8, error) {
+	physical, err := m.Translate(address, FaultFetch)
+	if err != nil {
+		return 0, err
+	}
+	return readCode(m.bus, physical)
+}
+
+// CheckExecute translates the address and passes the check on to the bus if it
+// is an ExecuteChecker
+func (m *MMU) CheckExecute(address uint16) error {
+	physical, err := m.Translate(address, FaultFetch)
+	if err != nil {
+		return err
+	}
+	if checker, ok := m.bus.(ExecuteChecker); ok {
+		return checker.CheckExecute(physical)
+	}
+	return nil
+}
+
+// Registers returns the device that controls the MMU, to be attached at
+// MMUPort
+func (m *MMU) Registers() Device {
+	return mmuRegisters{m}
+}
+
+type mmuRegisters struct {
+	mmu *MMU
+}
+
+func (r mmuRegisters) Read(offset uint16) (uint8, error) {
+	m := r.mmu
+	switch offset {
+	case mmuControlRegister:
+		return boolFlag(m.Enabled), nil
+	case mmuTableLowRegister:
+		return uint8(m.PageTableBase), nil
+	case mmuTableHighRegister:
+		return uint8(m.PageTableBase >> 8), nil
+	case mmuFaultLowRegister:
+		return uint8(m.FaultAddress), nil
+	case mmuFaultHighRegister:
+		return uint8(m.FaultAddress >> 8), nil
+	case mmuFaultCauseRegister:
+		return m.FaultCause, nil
+	}
+	return 0, fmt.Errorf("%w: read from MMU register %d", BUS_ERROR, offset)
+}
+
+func (r mmuRegisters) Write(offset uint16, value uint8) error {
+	m := r.mmu
+	switch offset {
+	case mmuControlRegister:
+		m.Enabled = value&1 != 0
+	case mmuTableLowRegister:
+		m.PageTableBase = m.PageTableBase&0xFF00 | uint16(value)
+	case mmuTableHighRegister:
+		m.PageTableBase = m.PageTableBase&0x00FF | uint16(value)<<8
+	default:
+		return fmt.Errorf("%w: write to read-only MMU register %d", BUS_ERROR, offset)
+	}
+	return nil
+}
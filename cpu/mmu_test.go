@@ -0,0 +1,284 @@
+package cpu
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testPageTable = 0x1000
+
+// identityMap maps every page to the frame with the same number
+func identityMap(mem *Memory, flags uint8) {
+	for page := range PageCount {
+		mem.Data[testPageTable+page*2] = uint8(page)
+		mem.Data[testPageTable+page*2+1] = flags
+	}
+}
+
+func TestMMUTranslate(t *testing.T) {
+	mem := NewMemory(DefaultLayout)
+	mmu := NewMMU(mem)
+
+	mem.Data[0x2005] = 7
+	if value, err := mmu.Read(0x2005); err != nil || value != 7 {
+		t.Errorf("Expected a disabled MMU to pass addresses through, got %d (%v)", value, err)
+	}
+
+	mmu.Enabled = true
+	mmu.PageTableBase = testPageTable
+	mem.Data[testPageTable+0x20*2], mem.Data[testPageTable+0x20*2+1] = 0x30, PagePresent|PageWritable
+	mem.Data[testPageTable+0x21*2], mem.Data[testPageTable+0x21*2+1] = 0x31, PagePresent
+	mem.Data[testPageTable+0x22*2], mem.Data[testPageTable+0x22*2+1] = 0x32, PagePresent|PageUser
+
+	mem.Data[0x3005] = 42
+	if value, err := mmu.Read(0x2005); err != nil || value != 42 {
+		t.Errorf("Expected to read 42 through the mapping, got %d (%v)", value, err)
+	}
+	if err := mmu.Write(0x2006, 9); err != nil || mem.Data[0x3006] != 9 {
+		t.Errorf("Expected to write through the mapping, got %d (%v)", mem.Data[0x3006], err)
+	}
+
+	tests := []struct {
+		name    string
+		user    bool
+		access  func() error
+		address uint16
+		cause   uint8
+	}{
+		{"write read-only page", false, func() error { return mmu.Write(0x2105, 1) }, 0x2105, FaultWrite | FaultPresent},
+		{"read missing page", false, func() error { _, err := mmu.Read(0x4000); return err }, 0x4000, 0},
+		{"fetch missing page", false, func() error { _, err := mmu.Fetch(0x4001); return err }, 0x4001, FaultFetch},
+		{"user read of kernel page", true, func() error { _, err := mmu.Read(0x2005); return err }, 0x2005, FaultUser | FaultPresent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mmu.UserMode = tt.user
+			defer func() { mmu.UserMode = false }()
+
+			if err := tt.access(); !errors.Is(err, PAGE_FAULT) {
+				t.Fatalf("Expected a page fault, got %v", err)
+			}
+			if mmu.FaultAddress != tt.address || mmu.FaultCause != tt.cause {
+				t.Errorf("Expected a fault at %#x with cause %#x, got %#x with cause %#x", tt.address, tt.cause, mmu.FaultAddress, mmu.FaultCause)
+			}
+		})
+	}
+
+	mmu.UserMode = true
+	if _, err := mmu.Read(0x2205); err != nil {
+		t.Errorf("Expected user mode to read a user page, got %v", err)
+	}
+}
+
+func TestMMURegisters(t *testing.T) {
+	mmu := NewMMU(NewMemory(DefaultLayout))
+	registers := mmu.Registers()
+
+	registers.Write(mmuTableLowRegister, 0x34)
+	registers.Write(mmuTableHighRegister, 0x12)
+	registers.Write(mmuControlRegister, 1)
+	if !mmu.Enabled || mmu.PageTableBase != 0x1234 {
+		t.Errorf("Expected the MMU enabled with its table at 0x1234, got %v and %#x", mmu.Enabled, mmu.PageTableBase)
+	}
+
+	mmu.FaultAddress, mmu.FaultCause = 0xABCD, FaultWrite
+	for offset, expected := range map[uint16]uint8{
+		mmuFaultLowRegister:   0xCD,
+		mmuFaultHighRegister:  0xAB,
+		mmuFaultCauseRegister: FaultWrite,
+	} {
+		if value, err := registers.Read(offset); err != nil || value != expected {
+			t.Errorf("Expected register %d to be %#x, got %#x (%v)", offset, expected, value, err)
+		}
+	}
+
+	if err := registers.Write(mmuFaultLowRegister, 0); !errors.Is(err, BUS_ERROR) {
+		t.Errorf("Expected a bus error writing the fault address, got %v", err)
+	}
+
+	mmu.PageTableBase = 0xFF00
+	if _, err := mmu.Read(0); !errors.Is(err, BUS_ERROR) {
+		t.Errorf("Expected a bus error for a page table past the end of memory, got %v", err)
+	}
+}
+
+func TestPageFaultTrap(t *testing.T) {
+	asm := NewAssembler([]string{
+		"LOAD R0 0x10",
+		"OUT 0x36 R0",
+		"LOAD R0 1",
+		"OUT 0x34 R0",
+		"LOADM R1 0x9000",
+		"HLT",
+		"handler:",
+		"IN R2 0x37",
+		"IN R3 0x38",
+		// Map the page the fault was in and run the load again
+		"STORE 0x1121 3",
+		"IRET",
+	}, DefaultLayout)
+	code, err := asm.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	cpu := NewCPU(DefaultLayout)
+	mem := NewMemory(DefaultLayout)
+	mem.LoadCode(code)
+	identityMap(mem, PagePresent|PageWritable)
+	mem.Data[testPageTable+0x90*2+1] = 0
+	mem.Data[0x9000] = 99
+
+	mmu := NewMMU(mem)
+	cpu.MapPorts(MMUPort, MMURegistersSize, mmu.Registers())
+	cpu.SetVector(mem, PageFaultVector, uint16(asm.LabelAddresses["handler"]))
+
+	if err := cpu.Execute(mmu); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.Registers[2] != 0x00 || cpu.Registers[3] != 0x90 {
+		t.Errorf("Expected the handler to see the fault at 0x9000, got %#02x%02x", cpu.Registers[3], cpu.Registers[2])
+	}
+	if cpu.Registers[1] != 99 {
+		t.Errorf("Expected the load to run again once the page was mapped, got %d", cpu.Registers[1])
+	}
+	if cpu.StackDepth() != 0 {
+		t.Errorf("Expected the handler to return, got stack depth %d", cpu.StackDepth())
+	}
+}
+
+func TestStorePageFault(t *testing.T) {
+	asm := NewAssembler([]string{
+		"LOAD R0 0x10",
+		"OUT 0x36 R0",
+		"LOAD R0 1",
+		"OUT 0x34 R0",
+		"STORE 0x4000 5",
+		"HLT",
+		"handler:",
+		"IN R2 0x39",
+		"HLT",
+	}, DefaultLayout)
+	code, err := asm.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	cpu := NewCPU(DefaultLayout)
+	mem := NewMemory(DefaultLayout)
+	mem.LoadCode(code)
+	identityMap(mem, PagePresent|PageWritable)
+	mem.Data[testPageTable+0x40*2+1] = 0
+
+	mmu := NewMMU(mem)
+	cpu.MapPorts(MMUPort, MMURegistersSize, mmu.Registers())
+	cpu.SetVector(mem, PageFaultVector, uint16(asm.LabelAddresses["handler"]))
+
+	if err := cpu.Execute(mmu); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.Registers[2]&FaultWrite == 0 || cpu.Registers[2]&FaultPresent != 0 {
+		t.Errorf("Expected the handler to see a write to a missing page, got cause %#x", cpu.Registers[2])
+	}
+}
+
+func TestPageFaultWithoutHandler(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R0 1",
+		"OUT 0x34 R0",
+		"STORE 0x9000 5",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+	identityMap(mem, PagePresent|PageWritable)
+	mem.Data[testPageTable+0x90*2+1] = PagePresent
+
+	mmu := NewMMU(mem)
+	mmu.PageTableBase = testPageTable
+	cpu.MapPorts(MMUPort, MMURegistersSize, mmu.Registers())
+
+	err = cpu.Execute(mmu)
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Type != PAGE_FAULT {
+		t.Fatalf("Expected a page fault, got %v", err)
+	}
+	if runtimeErr.ProgramCounter != CodeMemoryStart+6 || runtimeErr.Opcode != OP_STORE_AV {
+		t.Errorf("Expected the fault on the store, got %+v", runtimeErr)
+	}
+}
+
+func TestReadsPageFault(t *testing.T) {
+	asm := NewAssembler([]string{
+		"LOAD R0 0x10",
+		"OUT 0x36 R0",
+		"LOAD R0 1",
+		"OUT 0x34 R0",
+		// The line runs from a writable page into a read-only one
+		"READS 0x40FE",
+		"READS 0x5000",
+		"HLT",
+		"handler:",
+		"LOADM R3 0x40FE",
+		"STORE 0x1083 3",
+		"IRET",
+	}, DefaultLayout)
+	code, err := asm.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	cpu := NewCPU(DefaultLayout)
+	cpu.SetInput(strings.NewReader("hello\nworld\n"))
+	mem := NewMemory(DefaultLayout)
+	mem.LoadCode(code)
+	identityMap(mem, PagePresent|PageWritable)
+	mem.Data[testPageTable+0x41*2+1] = PagePresent
+
+	mmu := NewMMU(mem)
+	cpu.MapPorts(MMUPort, MMURegistersSize, mmu.Registers())
+	cpu.SetVector(mem, PageFaultVector, uint16(asm.LabelAddresses["handler"]))
+
+	if err := cpu.Execute(mmu); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.Registers[3] != 0 {
+		t.Errorf("Expected nothing to be stored before the fault, got %q", cpu.Registers[3])
+	}
+	if got := string(mem.Data[0x40FE:0x4104]); got != "hello\x00" {
+		t.Errorf("Expected the first line once the page was writable, got %q", got)
+	}
+	if got := string(mem.Data[0x5000:0x5006]); got != "world\x00" {
+		t.Errorf("Expected the second line to follow, got %q", got)
+	}
+}
+
+func TestPushPageFault(t *testing.T) {
+	cpu := NewCPU(DefaultLayout)
+	mem := NewMemory(DefaultLayout)
+	identityMap(mem, PagePresent|PageWritable)
+	mem.Data[testPageTable+0xFE*2+1] = PagePresent
+
+	mmu := NewMMU(mem)
+	mmu.Enabled = true
+	mmu.PageTableBase = testPageTable
+	// The return address straddles a writable page and a read-only one
+	cpu.StackLimit = 0xFE00
+	cpu.StackPointer = 0xFF00
+
+	if err := cpu.pushAddress(mmu, 0x1234); !errors.Is(err, PAGE_FAULT) {
+		t.Fatalf("Expected a page fault, got %v", err)
+	}
+	if mem.Data[0xFF00] != 0 {
+		t.Errorf("Expected no byte to be pushed, got %#x", mem.Data[0xFF00])
+	}
+	if cpu.StackPointer != 0xFF00 {
+		t.Errorf("Expected the stack pointer to be left alone, got %#x", cpu.StackPointer)
+	}
+}
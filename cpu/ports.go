@@ -0,0 +1,72 @@
+package cpu
+
+import "fmt"
+
+// PortCount is the size of the I/O port space used by IN and OUT
+const PortCount = 256
+
+type portMapping struct {
+	device Device
+	offset uint16
+}
+
+// MapPorts attaches a device to count ports starting at first. The device sees
+// the offset of the port from first, the same as when it is mapped onto an
+// AddressDecoder, so a device can be attached either way. Ports may not
+// already be mapped.
+func (c *CPU) MapPorts(first uint8, count int, device Device) error {
+	if count <= 0 || int(first)+count > PortCount {
+		return fmt.Errorf("invalid port range %d+%d", first, count)
+	}
+
+	for port := int(first); port < int(first)+count; port++ {
+		if c.ports[port].device != nil {
+			return fmt.Errorf("port %d is already mapped", port)
+		}
+	}
+
+	for offset := range count {
+		c.ports[int(first)+offset] = portMapping{device, uint16(offset)}
+	}
+	return nil
+}
+
+// Ports returns the I/O port space as a Bus, with each port at the address of
+// its number, for devices such as DMA that move data to and from ports
+func (c *CPU) Ports() Bus {
+	return portBus{c}
+}
+
+type portBus struct {
+	cpu *CPU
+}
+
+func (p portBus) Read(address uint16) (uint8, error) {
+	if address >= PortCount {
+		return 0, fmt.Errorf("%w: read from port %d past the port space", BUS_ERROR, address)
+	}
+	return p.cpu.in(uint8(address))
+}
+
+func (p portBus) Write(address uint16, value uint8) error {
+	if address >= PortCount {
+		return fmt.Errorf("%w: write to port %d past the port space", BUS_ERROR, address)
+	}
+	return p.cpu.out(uint8(address), value)
+}
+
+func (c *CPU) in(port uint8) (uint8, error) {
+	m := c.ports[port]
+	if m.device == nil {
+		return 0, fmt.Errorf("%w: read from unmapped port %d", BUS_ERROR, port)
+	}
+	return m.device.Read(m.offset)
+}
+
+func (c *CPU) out(port uint8, value uint8) error {
+	m := c.ports[port]
+	if m.device == nil {
+		return fmt.Errorf("%w: write to unmapped port %d", BUS_ERROR, port)
+	}
+	return m.device.Write(m.offset, value)
+}
@@ -0,0 +1,80 @@
+package cpu
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPortIO(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"LOAD R0 9",
+		"OUT 0x11 R0",
+		"IN R1 0x12",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	device := &latch{writes: make(map[uint16]uint8)}
+	if err := cpu.MapPorts(0x10, 4, device); err != nil {
+		t.Fatalf("Unexpected error mapping ports: %s", err)
+	}
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if device.writes[1] != 9 {
+		t.Errorf("Expected the device to see 9 at offset 1, got %v", device.writes)
+	}
+
+	if cpu.Registers[1] != 102 {
+		t.Errorf("Expected to read 102 from port offset 2, got %d", cpu.Registers[1])
+	}
+
+	instruction, _ := Decode(mem, CodeMemoryStart+3)
+	if instruction.String() != "OUT 17 R0" {
+		t.Errorf("Expected OUT 17 R0, got %s", instruction)
+	}
+}
+
+func TestMapPortsErrors(t *testing.T) {
+	cpu := NewCPU(DefaultLayout)
+	device := &latch{writes: make(map[uint16]uint8)}
+
+	if err := cpu.MapPorts(0xF0, 0x10, device); err != nil {
+		t.Fatalf("Unexpected error mapping the last ports: %s", err)
+	}
+
+	tests := []struct {
+		name  string
+		first uint8
+		count int
+	}{
+		{"overlapping", 0xE8, 0x10},
+		{"past the last port", 0x00, PortCount + 1},
+		{"empty", 0x00, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := cpu.MapPorts(tt.first, tt.count, device); err == nil {
+				t.Errorf("Expected mapping ports %d+%d to fail", tt.first, tt.count)
+			}
+		})
+	}
+}
+
+func TestUnmappedPort(t *testing.T) {
+	for _, line := range []string{"IN R0 3", "OUT 3 R0"} {
+		cpu, mem, err := prepCpuAndMem([]string{line, "HLT"})
+		if err != nil {
+			t.Fatalf("Error preparing CPU and memory: %s", err)
+		}
+
+		if err := cpu.Execute(mem); !errors.Is(err, BUS_ERROR) {
+			t.Errorf("Expected %q to be a bus error, got %v", line, err)
+		}
+	}
+}
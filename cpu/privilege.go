@@ -0,0 +1,154 @@
+package cpu
+
+import (
+	"fmt"
+	"slices"
+)
+
+// privilegedOpcodes may only run in supervisor mode. In user mode they trap to
+// PrivilegeVector instead. Port I/O covers the MMU registers and devices.
+var privilegedOpcodes = []Opcode{
+	OP_HLT_NONE,
+	OP_EI_NONE,
+	OP_DI_NONE,
+	OP_IRET_NONE,
+	OP_IN_RV,
+	OP_OUT_VR,
+	OP_SYSRET_NONE,
+}
+
+// statusUserMode is where the mode is saved in the status byte pushed when
+// entering a handler or making a system call
+const statusUserMode = 1 << 0
+
+// modeStatus returns the status byte saving a mode
+func modeStatus(user bool) uint8 {
+	if user {
+		return statusUserMode
+	}
+	return 0
+}
+
+// bankedStack is the stack of the mode the CPU isn't running in. Each mode has
+// its own stack, so user code can't see or overwrite what the supervisor
+// leaves on its stack.
+type bankedStack struct {
+	pointer uint16
+	top     uint16
+	limit   uint16
+}
+
+// noStack is the user stack until the supervisor sets one up. It is empty and
+// has no room, so any push overflows.
+var noStack = bankedStack{pointer: 0, top: 0, limit: 1}
+
+// ModeTracker is implemented by buses that treat accesses made in user mode
+// differently, such as the MMU. The CPU tells it the mode before each
+// instruction and whenever the mode changes.
+type ModeTracker interface {
+	SetUserMode(user bool)
+}
+
+// setMode switches between user and supervisor mode and their stacks
+func (c *CPU) setMode(bus Bus, user bool) {
+	if user != c.UserMode {
+		current := bankedStack{c.StackPointer, c.stackTop, c.StackLimit}
+		c.StackPointer, c.stackTop, c.StackLimit = c.otherStack.pointer, c.otherStack.top, c.otherStack.limit
+		c.otherStack = current
+	}
+	c.UserMode = user
+	if tracker, ok := bus.(ModeTracker); ok {
+		tracker.SetUserMode(user)
+	}
+}
+
+// userStack returns the user stack, which is only banked in supervisor mode
+func (c *CPU) userStack() bankedStack {
+	if c.UserMode {
+		return bankedStack{c.StackPointer, c.stackTop, c.StackLimit}
+	}
+	return c.otherStack
+}
+
+// setUserStack replaces the user stack
+func (c *CPU) setUserStack(stack bankedStack) {
+	if c.UserMode {
+		c.StackPointer, c.stackTop, c.StackLimit = stack.pointer, stack.top, stack.limit
+	} else {
+		c.otherStack = stack
+	}
+}
+
+// setUserStackTop gives user mode an empty stack growing down from top, the
+// size of the layout's stack
+func (c *CPU) setUserStackTop(top uint16) {
+	limit := max(int(top)-c.layout.StackSize+1, 0)
+	c.setUserStack(bankedStack{pointer: top, top: top, limit: uint16(limit)})
+}
+
+// checkPrivilege refuses privileged instructions in user mode
+func (c *CPU) checkPrivilege(opcode Opcode) error {
+	if !c.UserMode || !slices.Contains(privilegedOpcodes, opcode) {
+		return nil
+	}
+	name := "unknown"
+	if key, ok := opcodeKeys[opcode]; ok {
+		name = key.OpcodeName
+	}
+	return fmt.Errorf("%w: %s in user mode", PRIVILEGED_INSTRUCTION, name)
+}
+
+// syscall pushes the return address and the caller's mode on the supervisor
+// stack and jumps to the system call handler in supervisor mode. Unlike a trap
+// it leaves the flags and interrupts alone.
+func (c *CPU) syscall(bus Bus) error {
+	user := c.UserMode
+	c.setMode(bus, false)
+	stackPointer := c.StackPointer
+
+	handler, err := c.handlerAddress(bus, SyscallVector)
+	if err == nil && handler == 0 {
+		err = fmt.Errorf("%w: no handler for vector %d", UNHANDLED_INTERRUPT, SyscallVector)
+	}
+	if err == nil {
+		err = c.pushCall(bus, user)
+	}
+	if err != nil {
+		c.StackPointer = stackPointer
+		c.setMode(bus, user)
+		return err
+	}
+
+	c.ProgramCounter = handler
+	return nil
+}
+
+// pushCall pushes the return address and mode for sysret
+func (c *CPU) pushCall(bus Bus, user bool) error {
+	if err := c.reservePush(bus, 3); err != nil {
+		return err
+	}
+	if err := c.pushAddress(bus, c.ProgramCounter); err != nil {
+		return err
+	}
+	return c.push(bus, modeStatus(user))
+}
+
+// sysret pops the mode and return address pushed by syscall and returns to
+// them
+func (c *CPU) sysret(bus Bus) error {
+	if c.StackDepth() < 3 {
+		return STACK_UNDERFLOW
+	}
+	status, err := c.pop(bus)
+	if err != nil {
+		return err
+	}
+	address, err := c.popAddress(bus)
+	if err != nil {
+		return err
+	}
+	c.setMode(bus, status&statusUserMode != 0)
+	c.ProgramCounter = address
+	return nil
+}
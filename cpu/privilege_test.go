@@ -0,0 +1,325 @@
+package cpu
+
+import (
+	"errors"
+	"testing"
+)
+
+// enterUser starts the code after it in user mode
+var enterUser = []string{
+	"PUSH >user",
+	"PUSH <user",
+	"PUSH 1",
+	"SYSRET",
+	"user:",
+}
+
+func TestPrivilegedInstructionTrap(t *testing.T) {
+	asm := NewAssembler(append(append([]string{}, enterUser...),
+		"LOAD R0 1",
+		"OUT 0x31 R0",
+		"IN R1 0x31",
+		"SYSCALL",
+		// Skip the privileged instruction and return to user mode
+		"privileged:",
+		"ADD R3 1",
+		"LOADSP R2 2",
+		"ADD R2 3",
+		"STORESP R2 2",
+		"IRET",
+		"syscall:",
+		"HLT",
+	), DefaultLayout)
+	code, err := asm.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	cpu := NewCPU(DefaultLayout)
+	mem := NewMemory(DefaultLayout)
+	mem.LoadCode(code)
+	cpu.SetVector(mem, PrivilegeVector, uint16(asm.LabelAddresses["privileged"]))
+	cpu.SetVector(mem, SyscallVector, uint16(asm.LabelAddresses["syscall"]))
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// IRET went back to user mode, so the IN trapped as well
+	if cpu.Registers[3] != 2 {
+		t.Errorf("Expected two privileged instruction traps, got %d", cpu.Registers[3])
+	}
+	in := asm.LabelAddresses["user"] + 6
+	if cpu.Registers[2] != uint8(in+3) {
+		t.Errorf("Expected the trap to save the address of the IN, got %d", cpu.Registers[2]-3)
+	}
+	if cpu.Registers[0] != 1 || cpu.Registers[1] != 0 {
+		t.Errorf("Expected the privileged instructions to be undone, got %v", cpu.Registers)
+	}
+	if cpu.UserMode {
+		t.Errorf("Expected the system call handler to run in supervisor mode")
+	}
+}
+
+func TestPrivilegedInstructionWithoutHandler(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem(append(append([]string{}, enterUser...),
+		"DI",
+		"HLT",
+	))
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	err = cpu.Execute(mem)
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Type != PRIVILEGED_INSTRUCTION {
+		t.Fatalf("Expected a privileged instruction error, got %v", err)
+	}
+	if runtimeErr.ProgramCounter != CodeMemoryStart+7 || runtimeErr.Opcode != OP_DI_NONE {
+		t.Errorf("Expected the error on the DI, got %+v", runtimeErr)
+	}
+	if !cpu.UserMode {
+		t.Errorf("Expected the CPU to stop in user mode")
+	}
+
+	cpu.Reset()
+	if cpu.UserMode {
+		t.Errorf("Expected reset to return to supervisor mode")
+	}
+}
+
+func TestTrapOnFullStack(t *testing.T) {
+	asm := NewAssembler(append(append([]string{}, enterUser...),
+		"DI",
+		"HLT",
+		"handler:",
+		"HLT",
+	), DefaultLayout)
+	code, err := asm.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	cpu := NewCPU(DefaultLayout)
+	mem := NewMemory(DefaultLayout)
+	mem.LoadCode(code)
+	cpu.SetVector(mem, PrivilegeVector, uint16(asm.LabelAddresses["handler"]))
+	// Leave room for entering user code but not for a trap frame
+	cpu.StackPointer = cpu.StackLimit + 2
+
+	if err := cpu.Execute(mem); !errors.Is(err, STACK_OVERFLOW) {
+		t.Fatalf("Expected a stack overflow entering the handler, got %v", err)
+	}
+	if !cpu.UserMode {
+		t.Errorf("Expected the failed trap to stay in user mode")
+	}
+	if supervisor := cpu.otherStack; supervisor.pointer != supervisor.limit+2 {
+		t.Errorf("Expected the supervisor stack pointer to be left alone, got %+v", supervisor)
+	}
+}
+
+func TestSyscall(t *testing.T) {
+	asm := NewAssembler(append(append([]string{}, enterUser...),
+		"LOAD R0 4",
+		"SYSCALL",
+		"ADD R0 1",
+		"LOAD R3 1",
+		"SYSCALL",
+		"kernel:",
+		"CMP R3 1",
+		"JE exit",
+		"MUL R0 2",
+		"SYSRET",
+		"exit:",
+		"HLT",
+	), DefaultLayout)
+	code, err := asm.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	cpu := NewCPU(DefaultLayout)
+	mem := NewMemory(DefaultLayout)
+	mem.LoadCode(code)
+	cpu.SetVector(mem, SyscallVector, uint16(asm.LabelAddresses["kernel"]))
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.Registers[0] != 9 {
+		t.Errorf("Expected the system call to double R0 before the add, got %d", cpu.Registers[0])
+	}
+	// The second system call never returned
+	if cpu.StackDepth() != 3 {
+		t.Errorf("Expected the return address and mode on the stack, got depth %d", cpu.StackDepth())
+	}
+
+	cpu.Reset()
+	cpu.SetVector(mem, SyscallVector, 0)
+	if err := cpu.Execute(mem); !errors.Is(err, UNHANDLED_INTERRUPT) {
+		t.Errorf("Expected an unhandled interrupt without a handler, got %v", err)
+	}
+}
+
+func TestUserModePaging(t *testing.T) {
+	asm := NewAssembler(append([]string{
+		"LOAD R0 0x10",
+		"OUT 0x36 R0",
+		"LOAD R0 1",
+		"OUT 0x34 R0",
+	}, append(append([]string{}, enterUser...),
+		"LOADM R1 0x9000",
+		"SYSCALL",
+		"handler:",
+		"IN R2 0x39",
+		"HLT",
+	)...), DefaultLayout)
+	code, err := asm.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	cpu := NewCPU(DefaultLayout)
+	mem := NewMemory(DefaultLayout)
+	mem.LoadCode(code)
+	identityMap(mem, PagePresent|PageWritable|PageUser)
+	mem.Data[testPageTable+0x90*2+1] = PagePresent | PageWritable
+
+	mmu := NewMMU(mem)
+	cpu.MapPorts(MMUPort, MMURegistersSize, mmu.Registers())
+	cpu.SetVector(mem, PageFaultVector, uint16(asm.LabelAddresses["handler"]))
+
+	if err := cpu.Execute(mmu); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.Registers[2] != FaultUser|FaultPresent {
+		t.Errorf("Expected a user mode fault, got cause %#x", cpu.Registers[2])
+	}
+	if mmu.UserMode {
+		t.Errorf("Expected the MMU to follow the CPU into supervisor mode")
+	}
+}
+
+func TestUserStack(t *testing.T) {
+	asm := NewAssembler(append(append([]string{
+		// Give user mode a stack at 0x9000
+		"LOAD R0 0x00",
+		"OUT 0x3C R0",
+		"LOAD R0 0x90",
+		"OUT 0x3D R0",
+	}, enterUser...),
+		"PUSH 42",
+		"SYSCALL",
+		"POP R1",
+		"SYSCALL",
+		"kernel:",
+		"PUSH 7",
+		"POP R0",
+		"CMP R1 42",
+		"JE exit",
+		"IN R3 0x3E",
+		"SYSRET",
+		"exit:",
+		"HLT",
+	), DefaultLayout)
+	code, err := asm.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	cpu := NewCPU(DefaultLayout)
+	mem := NewMemory(DefaultLayout)
+	mem.LoadCode(code)
+	cpu.MapPorts(SystemPort, SystemRegistersSize, cpu.SystemRegisters())
+	cpu.SetVector(mem, SyscallVector, uint16(asm.LabelAddresses["kernel"]))
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.Registers[1] != 42 {
+		t.Errorf("Expected user code to pop what it pushed across a system call, got %d", cpu.Registers[1])
+	}
+	if cpu.Registers[3] != 0xFF {
+		t.Errorf("Expected the kernel to see the user stack pointer below the pushed byte, got %#x", cpu.Registers[3])
+	}
+	if mem.Data[0x9000] != 42 || mem.Data[0x8FFF] != 0 {
+		t.Errorf("Expected only the user's byte on the user stack, got %v", mem.Data[0x8FFE:0x9001])
+	}
+	if cpu.StackDepth() != 3 {
+		t.Errorf("Expected the last system call's frame on the supervisor stack, got depth %d", cpu.StackDepth())
+	}
+}
+
+func TestUserModeWithoutStack(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem(append(append([]string{}, enterUser...),
+		"PUSH 1",
+		"HLT",
+	))
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	if err := cpu.Execute(mem); !errors.Is(err, STACK_OVERFLOW) {
+		t.Errorf("Expected user code to have no stack until one is set, got %v", err)
+	}
+}
+
+func TestSupervisorSyscall(t *testing.T) {
+	asm := NewAssembler([]string{
+		"SYSCALL",
+		"HLT",
+		"kernel:",
+		"LOAD R0 1",
+		"SYSRET",
+	}, DefaultLayout)
+	code, err := asm.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+
+	cpu := NewCPU(DefaultLayout)
+	mem := NewMemory(DefaultLayout)
+	mem.LoadCode(code)
+	cpu.SetVector(mem, SyscallVector, uint16(asm.LabelAddresses["kernel"]))
+
+	// HLT would trap in user mode, so this only halts if SYSRET stayed in
+	// supervisor mode
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if cpu.Registers[0] != 1 || cpu.UserMode || cpu.StackDepth() != 0 {
+		t.Errorf("Expected the system call to return to supervisor mode, got R0 %d, user %v, depth %d", cpu.Registers[0], cpu.UserMode, cpu.StackDepth())
+	}
+}
+
+func TestSystemRegisters(t *testing.T) {
+	cpu := NewCPU(DefaultLayout)
+	registers := cpu.SystemRegisters()
+
+	registers.Write(systemUserStackLowRegister, 0xFF)
+	registers.Write(systemUserStackHighRegister, 0x7F)
+	if stack := cpu.userStack(); stack.top != 0x7FFF || stack.pointer != 0x7FFF || stack.limit != 0x7FFF-StackSize+1 {
+		t.Errorf("Expected an empty user stack at 0x7FFF, got %+v", stack)
+	}
+
+	registers.Write(systemUserPointerLowRegister, 0xF0)
+	if value, err := registers.Read(systemUserPointerLowRegister); err != nil || value != 0xF0 {
+		t.Errorf("Expected to read back the user stack pointer, got %#x (%v)", value, err)
+	}
+	if cpu.StackPointer != StackTop {
+		t.Errorf("Expected the supervisor stack to be left alone, got %#x", cpu.StackPointer)
+	}
+
+	// The user stack is swapped in while running user code
+	cpu.setMode(nil, true)
+	if cpu.StackPointer != 0x7FF0 || cpu.StackDepth() != 15 {
+		t.Errorf("Expected the user stack in user mode, got %#x with depth %d", cpu.StackPointer, cpu.StackDepth())
+	}
+	cpu.Reset()
+	if cpu.UserMode || cpu.StackPointer != StackTop || cpu.userStack() != noStack {
+		t.Errorf("Expected reset to return to the supervisor stack and drop the user stack")
+	}
+}
@@ -0,0 +1,101 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Permission is a set of access rights to a region of memory
+type Permission uint8
+
+const (
+	PermRead Permission = 1 << iota
+	PermWrite
+	PermExecute
+)
+
+func (p Permission) String() string {
+	var b strings.Builder
+	for _, right := range []struct {
+		perm Permission
+		char byte
+	}{{PermRead, 'r'}, {PermWrite, 'w'}, {PermExecute, 'x'}} {
+		if p&right.perm != 0 {
+			b.WriteByte(right.char)
+		} else {
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// Region is a named range of memory with its permissions
+type Region struct {
+	Name        string
+	Start       uint16
+	Size        int
+	Permissions Permission
+}
+
+func (r Region) contains(address uint16) bool {
+	return address >= r.Start && int(address) < int(r.Start)+r.Size
+}
+
+// ExecuteChecker is implemented by buses and devices that can refuse to have
+// instructions fetched from an address. The CPU checks every instruction byte
+// it fetches.
+type ExecuteChecker interface {
+	CheckExecute(address uint16) error
+}
+
+// Protect sets the regions of memory and their permissions. Once memory has
+// regions, accessing an address outside all of them is a protection fault.
+// Passing no regions turns protection off.
+func (m *Memory) Protect(regions ...Region) error {
+	for i, r := range regions {
+		if r.Size <= 0 || int(r.Start)+r.Size > TotalMemorySize {
+			return fmt.Errorf("region %s has an invalid range %#04x+%d", r.Name, r.Start, r.Size)
+		}
+		for _, other := range regions[:i] {
+			if int(r.Start) < int(other.Start)+other.Size && int(r.Start)+r.Size > int(other.Start) {
+				return fmt.Errorf("region %s overlaps region %s", r.Name, other.Name)
+			}
+		}
+	}
+	m.regions = regions
+	return nil
+}
+
+// Regions returns the protected regions of memory
+func (m *Memory) Regions() []Region {
+	return m.regions
+}
+
+func (m *Memory) CheckExecute(address uint16) error {
+	return m.check(address, PermExecute, "execute")
+}
+
+// check returns a protection fault naming the region and access if address
+// may not be accessed with perm
+func (m *Memory) check(address uint16, perm Permission, access string) error {
+	if len(m.regions) == 0 {
+		return nil
+	}
+
+	for _, r := range m.regions {
+		if !r.contains(address) {
+			continue
+		}
+		if r.Permissions&perm != 0 {
+			return nil
+		}
+		// Self-modifying code may write what it can execute and execute what
+		// it can write
+		if m.SelfModifying && perm != PermRead && r.Permissions&(PermWrite|PermExecute) != 0 {
+			return nil
+		}
+		return fmt.Errorf("%w: %s at address %d in %s region (%s)", PROTECTION_FAULT, access, address, r.Name, r.Permissions)
+	}
+
+	return fmt.Errorf("%w: %s at address %d outside any region", PROTECTION_FAULT, access, address)
+}
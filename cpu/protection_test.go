@@ -0,0 +1,140 @@
+package cpu
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProtectionFaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    []string
+		message string
+	}{
+		{"write to code", []string{"STORE 56 1", "HLT"}, "write at address 56 in code region (r-x)"},
+		{"execute data", []string{"JMP 10", "HLT"}, "execute at address 10 in data region (rw-)"},
+		{"execute stack", []string{"JMP 0xFFF0", "HLT"}, "execute at address 65520 in stack region (rw-)"},
+		{"register jump into heap", []string{"LOAD R0 200", "JMP R0", "HLT"}, "execute at address 200 in heap region (rw-)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu, mem, err := prepCpuAndMem(tt.code)
+			if err != nil {
+				t.Fatalf("Error preparing CPU and memory: %s", err)
+			}
+
+			err = cpu.Execute(mem)
+
+			var runtimeErr *RuntimeError
+			if !errors.As(err, &runtimeErr) || runtimeErr.Type != PROTECTION_FAULT {
+				t.Fatalf("Expected a protection fault, got %v", err)
+			}
+
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected the error to say %q, got %q", tt.message, err.Error())
+			}
+		})
+	}
+}
+
+func TestSelfModifyingCode(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		// Turn the LOAD below into a LOAD R0 99
+		"STORE 61 99",
+		"LOAD R0 1",
+		"JMP 0x1000",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+	mem.Data[0x1000] = uint8(OP_HLT_NONE)
+	mem.SelfModifying = true
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.Registers[0] != 99 {
+		t.Errorf("Expected the modified instruction to run, got R0 %d", cpu.Registers[0])
+	}
+}
+
+func TestProtect(t *testing.T) {
+	mem := NewMemory(DefaultLayout)
+
+	if err := mem.Protect(Region{"a", 0, 10, PermRead}, Region{"b", 9, 10, PermRead}); err == nil {
+		t.Errorf("Expected overlapping regions to be rejected")
+	}
+
+	if err := mem.Protect(Region{"a", 0xFFFF, 2, PermRead}); err == nil {
+		t.Errorf("Expected a region past the end of memory to be rejected")
+	}
+
+	if err := mem.Protect(Region{"rom", 0, 10, PermRead | PermExecute}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := mem.Write(5, 1); !errors.Is(err, PROTECTION_FAULT) {
+		t.Errorf("Expected writing a read-only region to fault, got %v", err)
+	}
+
+	if _, err := mem.Read(10); !errors.Is(err, PROTECTION_FAULT) || !strings.Contains(err.Error(), "outside any region") {
+		t.Errorf("Expected reading outside the regions to fault, got %v", err)
+	}
+
+	// Through an address decoder the check goes to the fallback memory
+	decoder := NewAddressDecoder()
+	decoder.SetFallback(mem)
+	decoder.Map(0x100, 1, NewRAM(1))
+	if err := decoder.CheckExecute(20); !errors.Is(err, PROTECTION_FAULT) {
+		t.Errorf("Expected the decoder to pass on the execute check, got %v", err)
+	}
+	if err := decoder.CheckExecute(0x100); err != nil {
+		t.Errorf("Expected mapped RAM to be executable, got %v", err)
+	}
+
+	mem.Protect()
+	if err := mem.Write(5, 1); err != nil {
+		t.Errorf("Expected no regions to turn protection off, got %v", err)
+	}
+
+	if perms := (PermRead | PermExecute).String(); perms != "r-x" {
+		t.Errorf("Expected r-x, got %s", perms)
+	}
+}
+
+func TestWriteOnlyRegion(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"STORE 10 7",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	regions := mem.Regions()
+	regions[0].Permissions = PermWrite
+	if err := mem.Protect(regions...); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Expected writing a write-only region to work, got %v", err)
+	}
+	if mem.Data[10] != 7 {
+		t.Errorf("Expected 7 at address 10, got %d", mem.Data[10])
+	}
+}
+
+func TestLoadCodeInvalidRegions(t *testing.T) {
+	// Stored memory runs into the code, which Validate would refuse
+	layout := DefaultLayout
+	layout.DataSize = int(layout.CodeOrigin) + 10
+	mem := NewMemory(layout)
+
+	if err := mem.LoadCode([]uint8{uint8(OP_HLT_NONE)}); err == nil {
+		t.Errorf("Expected overlapping regions to fail to load")
+	}
+}
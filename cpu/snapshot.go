@@ -0,0 +1,23 @@
+package cpu
+
+import "fmt"
+
+// Snapshotter is a device that can save its state when the program runs SNAP,
+// such as a display writing an image
+type Snapshotter interface {
+	Snapshot() error
+}
+
+// AddSnapshotter registers s to be snapshotted by the SNAP instruction
+func (c *CPU) AddSnapshotter(s Snapshotter) {
+	c.snapshotters = append(c.snapshotters, s)
+}
+
+func (c *CPU) snapshot() error {
+	for _, s := range c.snapshotters {
+		if err := s.Snapshot(); err != nil {
+			return fmt.Errorf("%w: snapshot: %v", IO_ERROR, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package cpu
+
+import (
+	"errors"
+	"testing"
+)
+
+type countingSnapshotter struct {
+	snapshots int
+	err       error
+}
+
+func (s *countingSnapshotter) Snapshot() error {
+	s.snapshots++
+	return s.err
+}
+
+func TestSnap(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"SNAP",
+		"SNAP",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	snapshotter := &countingSnapshotter{}
+	cpu.AddSnapshotter(snapshotter)
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if snapshotter.snapshots != 2 {
+		t.Errorf("Expected 2 snapshots, got %d", snapshotter.snapshots)
+	}
+
+	snapshotter.err = errors.New("disk full")
+	if err := cpu.Execute(mem); !errors.Is(err, IO_ERROR) {
+		t.Errorf("Expected a failed snapshot to be an I/O error, got %v", err)
+	}
+}
@@ -1,29 +1,96 @@
 package cpu
 
-const StackSize = 256
+// The stack grows down from the layout's stack top, which in the default layout
+// is the top of memory. The stack pointer holds the next free address, so the
+// most recently pushed byte is at StackPointer + 1. User mode has a stack of
+// its own, see privilege.go.
+const (
+	StackTop          = 0xFFFF
+	StackSize         = 256
+	DefaultStackLimit = StackTop - StackSize + 1
+)
 
-type Stack struct {
-	Data []uint8
+// StackDepth returns the number of bytes currently on the stack
+func (c *CPU) StackDepth() int {
+	return int(c.stackTop) - int(c.StackPointer)
 }
 
-func NewStack() *Stack {
-	return &Stack{
-		Data: []uint8{},
+// reserveStack checks that n more bytes fit between the stack pointer and the
+// stack limit, and within the execution limits
+func (c *CPU) reserveStack(n int) error {
+	if int(c.StackPointer)-n+1 < int(c.StackLimit) {
+		return STACK_OVERFLOW
 	}
+	if c.limits.MaxStackDepth > 0 && c.StackDepth()+n > c.limits.MaxStackDepth {
+		return STACK_LIMIT_EXCEEDED
+	}
+	return nil
+}
+
+// stackAddress returns the address of the byte offset bytes below the top of
+// the stack, so offset 0 is the most recently pushed byte
+func (c *CPU) stackAddress(offset uint8) (uint16, error) {
+	if int(offset) >= c.StackDepth() {
+		return 0, STACK_UNDERFLOW
+	}
+	return c.StackPointer + 1 + uint16(offset), nil
+}
+
+// reservePush is reserveStack for pushing n bytes at once, which also checks
+// that every byte can be written before any of them is
+func (c *CPU) reservePush(bus Bus, n int) error {
+	if err := c.reserveStack(n); err != nil {
+		return err
+	}
+	return checkWritable(bus, c.StackPointer-uint16(n-1), n)
+}
+
+func (c *CPU) push(bus Bus, value uint8) error {
+	if err := c.reserveStack(1); err != nil {
+		return err
+	}
+	if err := c.writeMemory(bus, c.StackPointer, value); err != nil {
+		return err
+	}
+	c.StackPointer--
+	return nil
 }
 
-func (s *Stack) Push(value uint8) {
-	s.Data = append(s.Data, value)
-	if len(s.Data) >= StackSize {
-		panic("Stack overflow")
+func (c *CPU) pop(bus Bus) (uint8, error) {
+	if c.StackDepth() == 0 {
+		return 0, STACK_UNDERFLOW
+	}
+	value, err := c.readMemory(bus, c.StackPointer+1)
+	if err != nil {
+		return 0, err
 	}
+	c.StackPointer++
+	return value, nil
 }
 
-func (s *Stack) Pop() uint8 {
-	if len(s.Data) == 0 {
-		panic("Stack underflow")
+// pushAddress pushes a return address, high byte first so that the low byte
+// is popped first
+func (c *CPU) pushAddress(bus Bus, address uint16) error {
+	if err := c.reservePush(bus, 2); err != nil {
+		return err
+	}
+	if err := c.push(bus, uint8(address>>8)); err != nil {
+		return err
+	}
+	return c.push(bus, uint8(address))
+}
+
+func (c *CPU) popAddress(bus Bus) (uint16, error) {
+	if c.StackDepth() < 2 {
+		return 0, STACK_UNDERFLOW
+	}
+	low, err := c.pop(bus)
+	if err != nil {
+		return 0, err
+	}
+	high, err := c.pop(bus)
+	if err != nil {
+		return 0, err
 	}
-	value := s.Data[len(s.Data)-1]
-	s.Data = s.Data[:len(s.Data)-1]
-	return value
+	return uint16(high)<<8 | uint16(low), nil
 }
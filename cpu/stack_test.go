@@ -0,0 +1,142 @@
+package cpu
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStackInMemory(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"PUSH 1",
+		"PUSH 2",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.Execute(mem)
+
+	if cpu.StackPointer != StackTop-2 {
+		t.Errorf("Expected stack pointer to be %d, got %d", StackTop-2, cpu.StackPointer)
+	}
+
+	if mem.Data[StackTop] != 1 || mem.Data[StackTop-1] != 2 {
+		t.Errorf("Expected the pushed bytes at the top of memory, got %d and %d", mem.Data[StackTop], mem.Data[StackTop-1])
+	}
+}
+
+func TestStackRelativeAccess(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"PUSH 10",
+		"PUSH 20",
+		"LOADSP R0 0",
+		"LOADSP R1 1",
+		"SUBSP 2",
+		"LOAD R2 99",
+		"STORESP R2 1",
+		"LOADSP R3 1",
+		"ADDSP 3",
+		"HLT",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.Registers[0] != 20 || cpu.Registers[1] != 10 {
+		t.Errorf("Expected to read 20 and 10 off the stack, got %d and %d", cpu.Registers[0], cpu.Registers[1])
+	}
+
+	if cpu.Registers[3] != 99 {
+		t.Errorf("Expected to read back the local, got %d", cpu.Registers[3])
+	}
+
+	if cpu.StackDepth() != 1 {
+		t.Errorf("Expected 1 byte left on the stack, got %d", cpu.StackDepth())
+	}
+}
+
+func TestRecursiveFunction(t *testing.T) {
+	// sum(n) = n + sum(n - 1), with n passed on the stack and the result in R0
+	cpu, mem, err := prepCpuAndMem([]string{
+		"PUSH 5",
+		"CALL sum",
+		"ADDSP 1",
+		"HLT",
+		"sum:",
+		// The return address takes offsets 0 and 1, so the argument is at 2
+		"LOADSP R1 2",
+		"CMP R1 0",
+		"JE base",
+		"DEC R1",
+		"PUSH R1",
+		"CALL sum",
+		"ADDSP 1",
+		"LOADSP R1 2",
+		"ADD R0 R1",
+		"RET",
+		"base:",
+		"LOAD R0 0",
+		"RET",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	if err := cpu.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cpu.Registers[0] != 15 {
+		t.Errorf("Expected sum(5) to be 15, got %d", cpu.Registers[0])
+	}
+
+	if cpu.StackDepth() != 0 {
+		t.Errorf("Expected the stack to be empty, got %d bytes", cpu.StackDepth())
+	}
+}
+
+func TestStackLimit(t *testing.T) {
+	cpu, mem, err := prepCpuAndMem([]string{
+		"loop:",
+		"PUSH 1",
+		"JMP loop",
+	})
+	if err != nil {
+		t.Fatalf("Error preparing CPU and memory: %s", err)
+	}
+
+	cpu.StackLimit = StackTop - 7
+
+	if err := cpu.Execute(mem); !errors.Is(err, STACK_OVERFLOW) {
+		t.Fatalf("Expected stack overflow, got %v", err)
+	}
+
+	if cpu.StackDepth() != 8 {
+		t.Errorf("Expected 8 bytes on the stack, got %d", cpu.StackDepth())
+	}
+}
+
+func TestStackUnderflow(t *testing.T) {
+	tests := [][]string{
+		{"RET"},
+		{"PUSH 1", "RET"},
+		{"PUSH 1", "LOADSP R0 1"},
+		{"PUSH 1", "ADDSP 2"},
+	}
+
+	for _, program := range tests {
+		cpu, mem, err := prepCpuAndMem(append(program, "HLT"))
+		if err != nil {
+			t.Fatalf("Error preparing CPU and memory: %s", err)
+		}
+
+		if err := cpu.Execute(mem); !errors.Is(err, STACK_UNDERFLOW) {
+			t.Errorf("Expected stack underflow for %v, got %v", program, err)
+		}
+	}
+}
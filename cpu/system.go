@@ -0,0 +1,82 @@
+package cpu
+
+import "fmt"
+
+// Ports of the system registers, which let the supervisor set up the CPU
+// itself. Like all ports they can't be used from user mode.
+const (
+	SystemPort          = 0x3C
+	SystemRegistersSize = 6
+)
+
+// System registers, as offsets from SystemPort
+const (
+	systemUserStackLowRegister    = iota // User stack top, low byte
+	systemUserStackHighRegister          // User stack top, high byte
+	systemUserPointerLowRegister         // User stack pointer, low byte
+	systemUserPointerHighRegister        // User stack pointer, high byte
+	systemInterruptMaskRegister          // Interrupt lines that are not serviced
+	systemPendingRegister                // Interrupt lines waiting to be serviced
+)
+
+// SystemRegisters returns the device holding the CPU's system registers, to be
+// attached at SystemPort.
+//
+// Writing either byte of the user stack top gives user mode an empty stack
+// that grows down from it, as large as the layout's stack. The user stack
+// pointer can then be read, for example to find arguments a system call was
+// passed on the stack, or written to switch between user programs.
+//
+// The interrupt mask register is CPU.InterruptMask. Reading the pending
+// register returns CPU.PendingIRQs, and writing it clears the lines set in the
+// value, so a program can poll masked lines.
+func (c *CPU) SystemRegisters() Device {
+	return systemRegisters{c}
+}
+
+type systemRegisters struct {
+	cpu *CPU
+}
+
+func (r systemRegisters) Read(offset uint16) (uint8, error) {
+	stack := r.cpu.userStack()
+	switch offset {
+	case systemUserStackLowRegister:
+		return uint8(stack.top), nil
+	case systemUserStackHighRegister:
+		return uint8(stack.top >> 8), nil
+	case systemUserPointerLowRegister:
+		return uint8(stack.pointer), nil
+	case systemUserPointerHighRegister:
+		return uint8(stack.pointer >> 8), nil
+	case systemInterruptMaskRegister:
+		return r.cpu.InterruptMask, nil
+	case systemPendingRegister:
+		return r.cpu.PendingIRQs(), nil
+	}
+	return 0, fmt.Errorf("%w: read from system register %d", BUS_ERROR, offset)
+}
+
+func (r systemRegisters) Write(offset uint16, value uint8) error {
+	c := r.cpu
+	stack := c.userStack()
+	switch offset {
+	case systemUserStackLowRegister:
+		c.setUserStackTop(stack.top&0xFF00 | uint16(value))
+	case systemUserStackHighRegister:
+		c.setUserStackTop(stack.top&0x00FF | uint16(value)<<8)
+	case systemUserPointerLowRegister:
+		stack.pointer = stack.pointer&0xFF00 | uint16(value)
+		c.setUserStack(stack)
+	case systemUserPointerHighRegister:
+		stack.pointer = stack.pointer&0x00FF | uint16(value)<<8
+		c.setUserStack(stack)
+	case systemInterruptMaskRegister:
+		c.InterruptMask = value
+	case systemPendingRegister:
+		c.clearIRQs(value)
+	default:
+		return fmt.Errorf("%w: write to system register %d", BUS_ERROR, offset)
+	}
+	return nil
+}
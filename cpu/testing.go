@@ -1,15 +1,17 @@
 package cpu
 
 func prepCpuAndMem(code []string) (cpu *CPU, mem *Memory, err error) {
-	asm := NewAssembler(code)
+	asm := NewAssembler(code, DefaultLayout)
 	bytecode, err := asm.Assemble()
 	if err != nil {
 		return nil, nil, err
 	}
 
-	cpu = NewCPU()
-	mem = NewMemory()
-	mem.LoadCode(bytecode)
+	cpu = NewCPU(DefaultLayout)
+	mem = NewMemory(DefaultLayout)
+	if err := mem.LoadCode(bytecode); err != nil {
+		return nil, nil, err
+	}
 
 	return cpu, mem, nil
 }
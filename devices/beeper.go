@@ -0,0 +1,194 @@
+package devices
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"cpu/cpu"
+)
+
+// Beeper register offsets
+const (
+	BeeperControl   = 0 // Write BeeperPlay to start a tone, anything else to stop it
+	BeeperStatus    = 1 // Status bits, read only
+	BeeperFrequency = 2 // Tone frequency in Hz, low byte first
+	BeeperDuration  = 4 // Tone length in milliseconds, low byte first
+	BeeperSize      = 6
+)
+
+// Beeper control values and status bits
+const (
+	BeeperPlay    = 1
+	BeeperPlaying = 1 << 0 // A tone is sounding
+)
+
+// Unsigned 8-bit samples centre on silence and swing by the amplitude
+const (
+	beeperSilence   = 128
+	beeperAmplitude = 48
+)
+
+// Default timing for NewBeeper
+const (
+	DefaultClockHz    = 1_000_000 // Emulated cycles per second
+	DefaultSampleRate = 22050
+)
+
+// Beeper is a square wave sound device. Tones are synthesized against
+// emulated time, counted in cycles, so the audio for a run is the same every
+// time. Add it to the CPU with AddTicker and write the result with SaveWAV.
+type Beeper struct {
+	clockHz    int
+	sampleRate int
+
+	frequency uint16
+	duration  uint16
+
+	cycles  uint64
+	samples []uint8
+
+	// The tone sounding, in samples from the start
+	toneStart int
+	toneEnd   int
+	toneFreq  int
+}
+
+// NewBeeper creates a beeper for a CPU running clockHz cycles per second,
+// sampling at sampleRate
+func NewBeeper(clockHz, sampleRate int) *Beeper {
+	return &Beeper{clockHz: clockHz, sampleRate: sampleRate}
+}
+
+func (b *Beeper) Tick(cycles int) {
+	b.cycles += uint64(cycles)
+	b.synthesize(int(b.cycles * uint64(b.sampleRate) / uint64(b.clockHz)))
+}
+
+// Finish lets a tone that is still sounding play out, for when the program
+// halts before it ends
+func (b *Beeper) Finish() {
+	b.synthesize(b.toneEnd)
+}
+
+// Samples returns the audio so far as unsigned 8-bit mono PCM
+func (b *Beeper) Samples() []uint8 {
+	return b.samples
+}
+
+// synthesize generates samples up to but not including end
+func (b *Beeper) synthesize(end int) {
+	for n := len(b.samples); n < end; n++ {
+		sample := uint8(beeperSilence)
+		if n >= b.toneStart && n < b.toneEnd && b.toneFreq > 0 {
+			// Two half periods per cycle of the wave
+			if (n-b.toneStart)*b.toneFreq*2/b.sampleRate%2 == 0 {
+				sample += beeperAmplitude
+			} else {
+				sample -= beeperAmplitude
+			}
+		}
+		b.samples = append(b.samples, sample)
+	}
+}
+
+func (b *Beeper) playing() bool {
+	return len(b.samples) < b.toneEnd
+}
+
+// SaveWAV writes the audio to a WAV file
+func (b *Beeper) SaveWAV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = b.WriteWAV(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// wavFormat is the fmt chunk of a WAV file
+type wavFormat struct {
+	Size          uint32
+	Format        uint16
+	Channels      uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+}
+
+// WriteWAV writes the audio as an unsigned 8-bit mono PCM WAV
+func (b *Beeper) WriteWAV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	size := uint32(len(b.samples))
+
+	bw.WriteString("RIFF")
+	binary.Write(bw, binary.LittleEndian, 36+size)
+	bw.WriteString("WAVEfmt ")
+	binary.Write(bw, binary.LittleEndian, wavFormat{
+		Size:          16,
+		Format:        1, // PCM
+		Channels:      1,
+		SampleRate:    uint32(b.sampleRate),
+		ByteRate:      uint32(b.sampleRate),
+		BlockAlign:    1,
+		BitsPerSample: 8,
+	})
+	bw.WriteString("data")
+	binary.Write(bw, binary.LittleEndian, size)
+	bw.Write(b.samples)
+
+	return bw.Flush()
+}
+
+func (b *Beeper) Read(offset uint16) (uint8, error) {
+	switch offset {
+	case BeeperControl:
+		return 0, nil
+	case BeeperStatus:
+		if b.playing() {
+			return BeeperPlaying, nil
+		}
+		return 0, nil
+	case BeeperFrequency:
+		return uint8(b.frequency), nil
+	case BeeperFrequency + 1:
+		return uint8(b.frequency >> 8), nil
+	case BeeperDuration:
+		return uint8(b.duration), nil
+	case BeeperDuration + 1:
+		return uint8(b.duration >> 8), nil
+	}
+	return 0, fmt.Errorf("%w: beeper has no register %d", cpu.BUS_ERROR, offset)
+}
+
+func (b *Beeper) Write(offset uint16, value uint8) error {
+	switch offset {
+	case BeeperControl:
+		now := len(b.samples)
+		if value == BeeperPlay {
+			b.toneStart = now
+			b.toneEnd = now + int(b.duration)*b.sampleRate/1000
+			b.toneFreq = int(b.frequency)
+		} else if b.toneEnd > now {
+			b.toneEnd = now
+		}
+	case BeeperStatus:
+	case BeeperFrequency:
+		b.frequency = b.frequency&0xFF00 | uint16(value)
+	case BeeperFrequency + 1:
+		b.frequency = b.frequency&0x00FF | uint16(value)<<8
+	case BeeperDuration:
+		b.duration = b.duration&0xFF00 | uint16(value)
+	case BeeperDuration + 1:
+		b.duration = b.duration&0x00FF | uint16(value)<<8
+	default:
+		return fmt.Errorf("%w: beeper has no register %d", cpu.BUS_ERROR, offset)
+	}
+	return nil
+}
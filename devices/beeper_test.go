@@ -0,0 +1,96 @@
+package devices
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"testing"
+)
+
+func TestBeeperTone(t *testing.T) {
+	// One sample per cycle keeps the arithmetic simple
+	beeper := NewBeeper(1000, 1000)
+	beeper.Write(BeeperFrequency, 250)
+	beeper.Write(BeeperDuration, 6)
+	beeper.Tick(2)
+	beeper.Write(BeeperControl, BeeperPlay)
+
+	if status, _ := beeper.Read(BeeperStatus); status != BeeperPlaying {
+		t.Errorf("Expected the tone to be playing")
+	}
+
+	beeper.Tick(10)
+
+	high, low := uint8(beeperSilence+beeperAmplitude), uint8(beeperSilence-beeperAmplitude)
+	expected := []uint8{128, 128, high, high, low, low, high, high, 128, 128, 128, 128}
+	if !slices.Equal(beeper.Samples(), expected) {
+		t.Errorf("Expected samples %v, got %v", expected, beeper.Samples())
+	}
+
+	if status, _ := beeper.Read(BeeperStatus); status != 0 {
+		t.Errorf("Expected the tone to have ended")
+	}
+}
+
+func TestBeeperStopAndFinish(t *testing.T) {
+	beeper := NewBeeper(1000, 1000)
+	beeper.Write(BeeperFrequency, 250)
+	beeper.Write(BeeperDuration, 100)
+	beeper.Write(BeeperControl, BeeperPlay)
+	beeper.Tick(3)
+	beeper.Write(BeeperControl, 0)
+	beeper.Tick(2)
+
+	if samples := beeper.Samples(); samples[3] != beeperSilence || samples[4] != beeperSilence {
+		t.Errorf("Expected silence after the tone is stopped, got %v", samples)
+	}
+
+	beeper.Write(BeeperControl, BeeperPlay)
+	beeper.Finish()
+	if len(beeper.Samples()) != 105 {
+		t.Errorf("Expected Finish to play out the tone to 105 samples, got %d", len(beeper.Samples()))
+	}
+}
+
+func TestBeeperProgram(t *testing.T) {
+	c, mem := prepCPU(t, []string{
+		"LOAD R0 0xB8",
+		"OUT 2 R0",
+		"LOAD R0 0x01",
+		"OUT 3 R0",
+		"LOAD R0 50",
+		"OUT 4 R0",
+		"LOAD R0 1",
+		"OUT 0 R0",
+		"HLT",
+	})
+
+	beeper := NewBeeper(DefaultClockHz, DefaultSampleRate)
+	c.AddTicker(beeper)
+	c.MapPorts(0, BeeperSize, beeper)
+
+	if err := c.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	beeper.Finish()
+
+	var output bytes.Buffer
+	if err := beeper.WriteWAV(&output); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	wav := output.Bytes()
+	if string(wav[0:4]) != "RIFF" || string(wav[8:16]) != "WAVEfmt " || string(wav[36:40]) != "data" {
+		t.Fatalf("Expected a WAV header, got %q", wav[:44])
+	}
+
+	if rate := binary.LittleEndian.Uint32(wav[24:28]); rate != DefaultSampleRate {
+		t.Errorf("Expected a sample rate of %d, got %d", DefaultSampleRate, rate)
+	}
+
+	// 50ms of a 440Hz tone
+	samples := DefaultSampleRate * 50 / 1000
+	if size := binary.LittleEndian.Uint32(wav[40:44]); int(size) != samples || len(wav) != 44+samples {
+		t.Errorf("Expected %d samples, header says %d and file has %d", samples, size, len(wav)-44)
+	}
+}
@@ -0,0 +1,137 @@
+package devices
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"cpu/cpu"
+)
+
+// Console register offsets
+const (
+	ConsoleData    = 0 // Read the next input byte, or write a character
+	ConsoleStatus  = 1 // Status bits, read only
+	ConsoleControl = 2 // Control bits
+	ConsoleSize    = 3
+)
+
+// Console status bits
+const (
+	ConsoleInputReady  = 1 << 0 // A byte is waiting to be read
+	ConsoleOutputReady = 1 << 1 // Writes are accepted, always set
+	ConsoleInputClosed = 1 << 2 // The input has ended and every byte has been read
+)
+
+// Console control bits
+const (
+	ConsoleInterruptOnReceive = 1 << 0 // Raise the console's interrupt when a byte arrives
+)
+
+// consoleBufferSize is how many input bytes are held before the host stream
+// stops being read
+const consoleBufferSize = 256
+
+// Console is a UART-style character device. Input is read from the host stream
+// in the background, so programs can poll the status register instead of
+// blocking on a read.
+type Console struct {
+	output io.Writer
+	input  chan byte
+	closed atomic.Bool
+
+	mu      sync.Mutex
+	control uint8
+	irq     irq
+}
+
+// NewConsole creates a console reading from in and writing to out, and starts
+// reading in
+func NewConsole(in io.Reader, out io.Writer) *Console {
+	c := &Console{
+		output: out,
+		input:  make(chan byte, consoleBufferSize),
+	}
+	go c.receive(bufio.NewReader(in))
+	return c
+}
+
+// SetInterrupt makes the console raise line on target when a byte arrives and
+// ConsoleInterruptOnReceive is set
+func (c *Console) SetInterrupt(target Interrupter, line uint8) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.irq = irq{target, line}
+}
+
+func (c *Console) receive(in io.ByteReader) {
+	for {
+		b, err := in.ReadByte()
+		if err != nil {
+			break
+		}
+		c.input <- b
+
+		c.mu.Lock()
+		if c.control&ConsoleInterruptOnReceive != 0 {
+			c.irq.raise()
+		}
+		c.mu.Unlock()
+	}
+	c.closed.Store(true)
+}
+
+func (c *Console) status() uint8 {
+	// closed is only set after the last byte is buffered, so check it first
+	closed := c.closed.Load()
+	status := uint8(ConsoleOutputReady)
+	if len(c.input) > 0 {
+		status |= ConsoleInputReady
+	} else if closed {
+		status |= ConsoleInputClosed
+	}
+	return status
+}
+
+func (c *Console) Read(offset uint16) (uint8, error) {
+	switch offset {
+	case ConsoleData:
+		select {
+		case b := <-c.input:
+			return b, nil
+		default:
+			return 0, nil
+		}
+	case ConsoleStatus:
+		return c.status(), nil
+	case ConsoleControl:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.control, nil
+	}
+	return 0, fmt.Errorf("%w: console has no register %d", cpu.BUS_ERROR, offset)
+}
+
+func (c *Console) Write(offset uint16, value uint8) error {
+	switch offset {
+	case ConsoleData:
+		if _, err := c.output.Write([]byte{value}); err != nil {
+			return fmt.Errorf("%w: %v", cpu.IO_ERROR, err)
+		}
+		return nil
+	case ConsoleStatus:
+		return nil
+	case ConsoleControl:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.control = value
+		// Bytes that arrived before interrupts were enabled still need one
+		if value&ConsoleInterruptOnReceive != 0 && len(c.input) > 0 {
+			c.irq.raise()
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: console has no register %d", cpu.BUS_ERROR, offset)
+}
@@ -0,0 +1,148 @@
+package devices
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cpu/cpu"
+)
+
+// recorder is an Interrupter that remembers which lines were raised
+type recorder struct {
+	mu    sync.Mutex
+	lines []uint8
+}
+
+func (r *recorder) RaiseIRQ(line uint8) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, line)
+}
+
+func (r *recorder) raised() []uint8 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]uint8(nil), r.lines...)
+}
+
+// waitFor polls until cond holds or a second has passed
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the device")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func prepCPU(t *testing.T, code []string) (*cpu.CPU, *cpu.Memory) {
+	t.Helper()
+	bytecode, err := cpu.NewAssembler(code, cpu.DefaultLayout).Assemble()
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err)
+	}
+	mem := cpu.NewMemory(cpu.DefaultLayout)
+	mem.LoadCode(bytecode)
+	return cpu.NewCPU(cpu.DefaultLayout), mem
+}
+
+func TestConsoleRegisters(t *testing.T) {
+	var output bytes.Buffer
+	console := NewConsole(strings.NewReader("ab"), &output)
+
+	for _, expected := range []uint8{'a', 'b'} {
+		waitFor(t, func() bool {
+			status, _ := console.Read(ConsoleStatus)
+			return status&ConsoleInputReady != 0
+		})
+		if b, _ := console.Read(ConsoleData); b != expected {
+			t.Errorf("Expected to read %q, got %q", expected, b)
+		}
+	}
+
+	waitFor(t, func() bool {
+		status, _ := console.Read(ConsoleStatus)
+		return status&ConsoleInputClosed != 0
+	})
+
+	status, _ := console.Read(ConsoleStatus)
+	if status != ConsoleOutputReady|ConsoleInputClosed {
+		t.Errorf("Expected status %03b, got %03b", ConsoleOutputReady|ConsoleInputClosed, status)
+	}
+
+	if b, _ := console.Read(ConsoleData); b != 0 {
+		t.Errorf("Expected 0 with no input waiting, got %d", b)
+	}
+
+	console.Write(ConsoleData, 'x')
+	if output.String() != "x" {
+		t.Errorf("Expected output %q, got %q", "x", output.String())
+	}
+
+	if _, err := console.Read(ConsoleSize); err == nil {
+		t.Errorf("Expected reading past the registers to fail")
+	}
+}
+
+func TestConsoleEcho(t *testing.T) {
+	c, mem := prepCPU(t, []string{
+		"loop:",
+		"IN R0 1",
+		"LOAD R1 R0",
+		"AND R1 4",
+		"JNZ done",
+		"AND R0 1",
+		"JZ loop",
+		"IN R2 0",
+		"OUT 0 R2",
+		"JMP loop",
+		"done:",
+		"HLT",
+	})
+
+	var output bytes.Buffer
+	if err := c.MapPorts(0, ConsoleSize, NewConsole(strings.NewReader("hello"), &output)); err != nil {
+		t.Fatalf("Unexpected error mapping the console: %s", err)
+	}
+
+	if err := c.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if output.String() != "hello" {
+		t.Errorf("Expected output %q, got %q", "hello", output.String())
+	}
+}
+
+func TestConsoleInterrupt(t *testing.T) {
+	reader, writer := io.Pipe()
+	console := NewConsole(reader, io.Discard)
+	target := &recorder{}
+	console.SetInterrupt(target, 3)
+
+	writer.Write([]byte{'a'})
+	waitFor(t, func() bool {
+		status, _ := console.Read(ConsoleStatus)
+		return status&ConsoleInputReady != 0
+	})
+
+	if len(target.raised()) != 0 {
+		t.Errorf("Expected no interrupt while disabled, got %v", target.raised())
+	}
+
+	// Enabling with input already waiting raises straight away
+	console.Write(ConsoleControl, ConsoleInterruptOnReceive)
+	if lines := target.raised(); len(lines) != 1 || lines[0] != 3 {
+		t.Errorf("Expected line 3 to be raised, got %v", lines)
+	}
+
+	writer.Write([]byte{'b'})
+	waitFor(t, func() bool { return len(target.raised()) == 2 })
+	writer.Close()
+}
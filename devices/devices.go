@@ -0,0 +1,21 @@
+// Package devices has peripherals that can be attached to a cpu.CPU, either on
+// an address decoder or on I/O ports. Every device implements cpu.Device and
+// is addressed by register offsets from the start of where it is mapped.
+package devices
+
+// Interrupter is what a device raises interrupts on, normally a *cpu.CPU
+type Interrupter interface {
+	RaiseIRQ(line uint8)
+}
+
+// irq is an optional interrupt line for a device
+type irq struct {
+	target Interrupter
+	line   uint8
+}
+
+func (i irq) raise() {
+	if i.target != nil {
+		i.target.RaiseIRQ(i.line)
+	}
+}
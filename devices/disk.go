@@ -0,0 +1,200 @@
+package devices
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"cpu/cpu"
+)
+
+// Disk register offsets
+const (
+	DiskCommand   = 0 // Write a command to run it
+	DiskStatus    = 1 // Result of the last command, read only
+	DiskSectorLo  = 2 // Low byte of the sector number
+	DiskSectorHi  = 3 // High byte of the sector number
+	DiskBufferLo  = 4 // Low byte of the memory address to transfer to or from
+	DiskBufferHi  = 5 // High byte of the memory address to transfer to or from
+	DiskSectorsLo = 6 // Low byte of the image size in sectors, read only
+	DiskSectorsHi = 7 // High byte of the image size in sectors, read only
+	DiskSize      = 8
+)
+
+// Disk commands
+const (
+	DiskRead  = 1 // Copy the sector into memory at the buffer address
+	DiskWrite = 2 // Copy memory at the buffer address into the sector
+)
+
+// Disk status codes
+const (
+	DiskOK            = 0
+	DiskErrorCommand  = 1 // Unknown command
+	DiskErrorReadOnly = 2 // Write to a read-only disk
+	DiskErrorIO       = 3 // The host file could not be read or written
+	DiskErrorBus      = 4 // The buffer is not all readable or writable memory
+)
+
+// DiskOptions configures how a disk image is opened
+type DiskOptions struct {
+	SectorSize int  // 128 or 256, 256 if unset
+	ReadOnly   bool // Refuse writes and open the image read only
+	Create     bool // Create the image if it does not exist
+}
+
+// Disk is a block storage device backed by a host image file. Sectors are
+// copied to and from memory over the bus when a command is written, so the
+// transfer has finished by the next instruction. Sectors past the end of the
+// image read as zeros and writing them grows the image.
+type Disk struct {
+	file       *os.File
+	bus        cpu.Bus
+	sectorSize int
+	readOnly   bool
+
+	status  uint8
+	command uint8
+	sector  uint16
+	buffer  uint16
+}
+
+// OpenDisk opens the image at path, transferring sectors over bus
+func OpenDisk(path string, bus cpu.Bus, options DiskOptions) (*Disk, error) {
+	sectorSize := options.SectorSize
+	if sectorSize == 0 {
+		sectorSize = 256
+	}
+	if sectorSize != 128 && sectorSize != 256 {
+		return nil, fmt.Errorf("sector size must be 128 or 256, got %d", sectorSize)
+	}
+
+	flags := os.O_RDWR
+	if options.ReadOnly {
+		flags = os.O_RDONLY
+	}
+	if options.Create && !options.ReadOnly {
+		flags |= os.O_CREATE
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Disk{
+		file:       file,
+		bus:        bus,
+		sectorSize: sectorSize,
+		readOnly:   options.ReadOnly,
+	}, nil
+}
+
+// Close closes the image file
+func (d *Disk) Close() error {
+	return d.file.Close()
+}
+
+func (d *Disk) sectors() int {
+	info, err := d.file.Stat()
+	if err != nil {
+		return 0
+	}
+	return int((info.Size() + int64(d.sectorSize) - 1) / int64(d.sectorSize))
+}
+
+func (d *Disk) run(command uint8) uint8 {
+	switch command {
+	case DiskRead:
+		return d.readSector()
+	case DiskWrite:
+		return d.writeSector()
+	}
+	return DiskErrorCommand
+}
+
+func (d *Disk) readSector() uint8 {
+	data := make([]uint8, d.sectorSize)
+	_, err := d.file.ReadAt(data, int64(d.sector)*int64(d.sectorSize))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return DiskErrorIO
+	}
+
+	for i, b := range data {
+		address := int(d.buffer) + i
+		if address >= cpu.TotalMemorySize {
+			return DiskErrorBus
+		}
+		if err := d.bus.Write(uint16(address), b); err != nil {
+			return DiskErrorBus
+		}
+	}
+	return DiskOK
+}
+
+func (d *Disk) writeSector() uint8 {
+	if d.readOnly {
+		return DiskErrorReadOnly
+	}
+
+	data := make([]uint8, d.sectorSize)
+	for i := range data {
+		address := int(d.buffer) + i
+		if address >= cpu.TotalMemorySize {
+			return DiskErrorBus
+		}
+		b, err := d.bus.Read(uint16(address))
+		if err != nil {
+			return DiskErrorBus
+		}
+		data[i] = b
+	}
+
+	if _, err := d.file.WriteAt(data, int64(d.sector)*int64(d.sectorSize)); err != nil {
+		return DiskErrorIO
+	}
+	return DiskOK
+}
+
+func (d *Disk) Read(offset uint16) (uint8, error) {
+	switch offset {
+	case DiskCommand:
+		return d.command, nil
+	case DiskStatus:
+		return d.status, nil
+	case DiskSectorLo:
+		return uint8(d.sector), nil
+	case DiskSectorHi:
+		return uint8(d.sector >> 8), nil
+	case DiskBufferLo:
+		return uint8(d.buffer), nil
+	case DiskBufferHi:
+		return uint8(d.buffer >> 8), nil
+	case DiskSectorsLo:
+		return uint8(d.sectors()), nil
+	case DiskSectorsHi:
+		return uint8(d.sectors() >> 8), nil
+	}
+	return 0, fmt.Errorf("%w: disk has no register %d", cpu.BUS_ERROR, offset)
+}
+
+func (d *Disk) Write(offset uint16, value uint8) error {
+	switch offset {
+	case DiskCommand:
+		d.command = value
+		d.status = d.run(value)
+	case DiskStatus, DiskSectorsLo, DiskSectorsHi:
+	case DiskSectorLo:
+		d.sector = d.sector&0xFF00 | uint16(value)
+	case DiskSectorHi:
+		d.sector = d.sector&0x00FF | uint16(value)<<8
+	case DiskBufferLo:
+		d.buffer = d.buffer&0xFF00 | uint16(value)
+	case DiskBufferHi:
+		d.buffer = d.buffer&0x00FF | uint16(value)<<8
+	default:
+		return fmt.Errorf("%w: disk has no register %d", cpu.BUS_ERROR, offset)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package devices
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cpu/cpu"
+)
+
+func TestDiskProgram(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.img")
+
+	// Write a sector from one run and read it back in another
+	for i, code := range [][]string{
+		{
+			"STORE 0x1000 42",
+			"STORE 0x107F 7",
+			"LOAD R0 3",
+			"OUT 2 R0",
+			"LOAD R0 0x10",
+			"OUT 5 R0",
+			"LOAD R0 2",
+			"OUT 0 R0",
+			"IN R3 1",
+			"HLT",
+		},
+		{
+			"LOAD R0 3",
+			"OUT 2 R0",
+			"LOAD R0 0x20",
+			"OUT 5 R0",
+			"LOAD R0 1",
+			"OUT 0 R0",
+			"IN R3 1",
+			"HLT",
+		},
+	} {
+		c, mem := prepCPU(t, code)
+		disk, err := OpenDisk(path, mem, DiskOptions{SectorSize: 128, Create: true})
+		if err != nil {
+			t.Fatalf("Unexpected error opening the disk: %s", err)
+		}
+		c.MapPorts(0, DiskSize, disk)
+
+		if err := c.Execute(mem); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		disk.Close()
+
+		if c.Registers[3] != DiskOK {
+			t.Fatalf("Expected status %d, got %d", DiskOK, c.Registers[3])
+		}
+
+		if i == 1 && (mem.Data[0x2000] != 42 || mem.Data[0x207F] != 7) {
+			t.Errorf("Expected to read the sector back, got %d and %d", mem.Data[0x2000], mem.Data[0x207F])
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if info.Size() != 4*128 {
+		t.Errorf("Expected the image to grow to 4 sectors, got %d bytes", info.Size())
+	}
+}
+
+func TestDiskErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.img")
+
+	if _, err := OpenDisk(path, cpu.NewMemory(cpu.DefaultLayout), DiskOptions{}); err == nil {
+		t.Errorf("Expected opening a missing image without Create to fail")
+	}
+
+	if _, err := OpenDisk(path, cpu.NewMemory(cpu.DefaultLayout), DiskOptions{SectorSize: 512, Create: true}); err == nil {
+		t.Errorf("Expected a 512 byte sector size to be rejected")
+	}
+
+	os.WriteFile(path, make([]byte, 300), 0644)
+
+	decoder := cpu.NewAddressDecoder()
+	decoder.Map(0, 0x100, cpu.NewRAM(0x100))
+	disk, err := OpenDisk(path, decoder, DiskOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("Unexpected error opening the disk: %s", err)
+	}
+	defer disk.Close()
+
+	if sectors, _ := disk.Read(DiskSectorsLo); sectors != 2 {
+		t.Errorf("Expected a 300 byte image to be 2 sectors, got %d", sectors)
+	}
+
+	tests := []struct {
+		name    string
+		buffer  uint16
+		command uint8
+		status  uint8
+	}{
+		{"read", 0x0000, DiskRead, DiskOK},
+		{"write to read only disk", 0x0000, DiskWrite, DiskErrorReadOnly},
+		{"buffer past mapped memory", 0x0080, DiskRead, DiskErrorBus},
+		{"unknown command", 0x0000, 9, DiskErrorCommand},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			disk.Write(DiskBufferLo, uint8(tt.buffer))
+			disk.Write(DiskBufferHi, uint8(tt.buffer>>8))
+			disk.Write(DiskCommand, tt.command)
+
+			if status, _ := disk.Read(DiskStatus); status != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, status)
+			}
+		})
+	}
+}
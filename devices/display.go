@@ -0,0 +1,136 @@
+package devices
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"cpu/cpu"
+)
+
+// Text display dimensions. Each cell is a character byte followed by an
+// attribute byte, row by row from the top left.
+const (
+	TextColumns     = 40
+	TextRows        = 25
+	TextDisplaySize = TextColumns * TextRows * 2
+)
+
+// DefaultTextDisplayBase is where the CLI maps the text display, below the
+// interrupt vector table
+const DefaultTextDisplayBase = 0xE000
+
+// TextDisplay is a character framebuffer. The low nibble of an attribute is
+// the foreground colour and the high nibble the background, using the 16
+// ANSI colours, except that 0 leaves the terminal's default colours.
+type TextDisplay struct {
+	cells [TextDisplaySize]uint8
+	dirty bool
+
+	output   io.Writer
+	interval int
+	elapsed  int
+}
+
+func NewTextDisplay() *TextDisplay {
+	return &TextDisplay{}
+}
+
+// RenderTo redraws the display on a terminal every interval cycles, if it has
+// changed. Add the display to the CPU with AddTicker for this to happen.
+func (d *TextDisplay) RenderTo(output io.Writer, interval int) {
+	d.output = output
+	d.interval = interval
+}
+
+func (d *TextDisplay) Tick(cycles int) {
+	if d.output == nil {
+		return
+	}
+	d.elapsed += cycles
+	if d.elapsed < d.interval {
+		return
+	}
+	d.elapsed = 0
+	if d.dirty {
+		d.Render(d.output)
+	}
+}
+
+// Render draws the display at the top left of a terminal using ANSI escape
+// sequences
+func (d *TextDisplay) Render(output io.Writer) error {
+	w := bufio.NewWriter(output)
+	w.WriteString("\x1b[H")
+	for row := range TextRows {
+		var current uint8
+		for column := range TextColumns {
+			char, attribute := d.cell(row, column)
+			if attribute != current {
+				w.WriteString(sgr(attribute))
+				current = attribute
+			}
+			w.WriteByte(char)
+		}
+		w.WriteString("\x1b[0m\r\n")
+	}
+	d.dirty = false
+	return w.Flush()
+}
+
+// Text returns the characters on the display without attributes, one line per
+// row with trailing spaces removed
+func (d *TextDisplay) Text() string {
+	lines := make([]string, TextRows)
+	for row := range TextRows {
+		line := make([]byte, TextColumns)
+		for column := range TextColumns {
+			line[column], _ = d.cell(row, column)
+		}
+		lines[row] = strings.TrimRight(string(line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cell returns the printable character and attribute at a position
+func (d *TextDisplay) cell(row, column int) (uint8, uint8) {
+	i := (row*TextColumns + column) * 2
+	char := d.cells[i]
+	if char < ' ' || char > '~' {
+		char = ' '
+	}
+	return char, d.cells[i+1]
+}
+
+// sgr is the escape sequence selecting the colours of an attribute
+func sgr(attribute uint8) string {
+	if attribute == 0 {
+		return "\x1b[0m"
+	}
+	return fmt.Sprintf("\x1b[0;%d;%dm", ansiColour(attribute&0x0F, 30), ansiColour(attribute>>4, 40))
+}
+
+// ansiColour maps a colour from 0 to 15 onto the normal or bright ANSI codes
+func ansiColour(colour uint8, base int) int {
+	if colour >= 8 {
+		return base + 60 + int(colour-8)
+	}
+	return base + int(colour)
+}
+
+func (d *TextDisplay) Read(offset uint16) (uint8, error) {
+	if int(offset) >= TextDisplaySize {
+		return 0, fmt.Errorf("%w: read at offset %d", cpu.MEMORY_OUT_OF_BOUNDS, offset)
+	}
+	return d.cells[offset], nil
+}
+
+func (d *TextDisplay) Write(offset uint16, value uint8) error {
+	if int(offset) >= TextDisplaySize {
+		return fmt.Errorf("%w: write at offset %d", cpu.MEMORY_OUT_OF_BOUNDS, offset)
+	}
+	d.cells[offset] = value
+	d.dirty = true
+	return nil
+}
@@ -0,0 +1,75 @@
+package devices
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"cpu/cpu"
+)
+
+func TestTextDisplayProgram(t *testing.T) {
+	c, mem := prepCPU(t, []string{
+		"STORE 0xE000 H",
+		"STORE 0xE002 i",
+		"STORE 0xE050 !",
+		"STORE 0xE051 0x1F",
+		"HLT",
+	})
+
+	display := NewTextDisplay()
+	decoder := cpu.NewAddressDecoder()
+	decoder.SetFallback(mem)
+	decoder.Map(DefaultTextDisplayBase, TextDisplaySize, display)
+
+	if err := c.Execute(decoder); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := "Hi\n!" + strings.Repeat("\n", TextRows-2)
+	if display.Text() != expected {
+		t.Errorf("Expected text %q, got %q", expected, display.Text())
+	}
+
+	if mem.Data[DefaultTextDisplayBase] != 0 {
+		t.Errorf("Expected the write to go to the display, not memory")
+	}
+}
+
+func TestTextDisplayRender(t *testing.T) {
+	display := NewTextDisplay()
+	var output bytes.Buffer
+	display.RenderTo(&output, 10)
+
+	display.Tick(10)
+	if output.Len() != 0 {
+		t.Errorf("Expected an unchanged display not to be drawn")
+	}
+
+	display.Write(0, 'A')
+	display.Write(1, 0x4F)
+	display.Tick(9)
+	if output.Len() != 0 {
+		t.Errorf("Expected nothing to be drawn before the interval")
+	}
+
+	display.Tick(1)
+	rendered := output.String()
+	if !strings.HasPrefix(rendered, "\x1b[H\x1b[0;97;44mA\x1b[0m ") {
+		t.Errorf("Expected white on blue A at the top left, got %q", rendered[:min(len(rendered), 30)])
+	}
+
+	if strings.Count(rendered, "\r\n") != TextRows {
+		t.Errorf("Expected %d rows, got %d", TextRows, strings.Count(rendered, "\r\n"))
+	}
+
+	output.Reset()
+	display.Tick(10)
+	if output.Len() != 0 {
+		t.Errorf("Expected the display not to be redrawn until it changes")
+	}
+
+	if err := display.Write(TextDisplaySize, 0); err == nil {
+		t.Errorf("Expected writing past the display to fail")
+	}
+}
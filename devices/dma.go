@@ -0,0 +1,185 @@
+package devices
+
+import (
+	"fmt"
+
+	"cpu/cpu"
+)
+
+// DMA register offsets
+const (
+	DMAControl     = 0 // Control bits, setting DMAStart starts a transfer
+	DMAStatus      = 1 // Status bits, write anything to clear done and error
+	DMASource      = 2 // Source address, low byte first
+	DMADestination = 4 // Destination address, low byte first
+	DMALength      = 6 // Bytes to copy, low byte first
+	DMASize        = 8
+)
+
+// DMA control bits
+const (
+	DMAStart           = 1 << 0 // Start a transfer
+	DMAInterrupt       = 1 << 1 // Raise the DMA interrupt when a transfer finishes
+	DMAHoldSource      = 1 << 2 // Read every byte from the source address, such as a device's data register
+	DMAHoldDestination = 1 << 3 // Write every byte to the destination address
+	DMASourcePort      = 1 << 4 // The source is an I/O port rather than memory
+	DMADestinationPort = 1 << 5 // The destination is an I/O port rather than memory
+)
+
+// DMA status bits
+const (
+	DMABusy  = 1 << 0 // A transfer is in progress
+	DMADone  = 1 << 1 // A transfer has finished
+	DMAError = 1 << 2 // The last transfer stopped on a bus error
+)
+
+// DMA transfers take a setup cost plus a cost per byte, in cycles
+const (
+	DMASetupCycles   = 4
+	DMACyclesPerByte = 1
+)
+
+// DMA is a controller that copies blocks over the bus, or to and from I/O
+// ports once given them with SetPorts. A transfer completes once its cycle cost
+// has passed, so the copy is seen by the program at the same point on every
+// run. Add it to the CPU with AddTicker.
+type DMA struct {
+	bus   cpu.Bus
+	ports cpu.Bus
+	irq   irq
+
+	control     uint8
+	status      uint8
+	source      uint16
+	destination uint16
+	length      uint16
+	remaining   int
+}
+
+func NewDMA(bus cpu.Bus) *DMA {
+	return &DMA{bus: bus}
+}
+
+// SetInterrupt makes the controller raise line on target when a transfer
+// finishes and DMAInterrupt is set
+func (d *DMA) SetInterrupt(target Interrupter, line uint8) {
+	d.irq = irq{target, line}
+}
+
+// SetPorts lets transfers use ports as their source or destination, such as
+// the CPU's Ports
+func (d *DMA) SetPorts(ports cpu.Bus) {
+	d.ports = ports
+}
+
+func (d *DMA) Tick(cycles int) {
+	if d.status&DMABusy == 0 {
+		return
+	}
+	d.remaining -= cycles
+	if d.remaining > 0 {
+		return
+	}
+
+	d.status &^= DMABusy
+	d.status |= DMADone
+	if !d.transfer() {
+		d.status |= DMAError
+	}
+	if d.control&DMAInterrupt != 0 {
+		d.irq.raise()
+	}
+}
+
+// transfer copies the block, returning false if it stopped on a bus error
+func (d *DMA) transfer() bool {
+	from, to := d.bus, d.bus
+	if d.control&DMASourcePort != 0 {
+		from = d.ports
+	}
+	if d.control&DMADestinationPort != 0 {
+		to = d.ports
+	}
+	if from == nil || to == nil {
+		return false
+	}
+
+	source, destination := int(d.source), int(d.destination)
+	for range int(d.length) {
+		if source >= cpu.TotalMemorySize || destination >= cpu.TotalMemorySize {
+			return false
+		}
+
+		value, err := from.Read(uint16(source))
+		if err != nil {
+			return false
+		}
+		if err := to.Write(uint16(destination), value); err != nil {
+			return false
+		}
+
+		if d.control&DMAHoldSource == 0 {
+			source++
+		}
+		if d.control&DMAHoldDestination == 0 {
+			destination++
+		}
+	}
+	return true
+}
+
+func (d *DMA) Read(offset uint16) (uint8, error) {
+	switch offset {
+	case DMAControl:
+		return d.control, nil
+	case DMAStatus:
+		return d.status, nil
+	case DMASource:
+		return uint8(d.source), nil
+	case DMASource + 1:
+		return uint8(d.source >> 8), nil
+	case DMADestination:
+		return uint8(d.destination), nil
+	case DMADestination + 1:
+		return uint8(d.destination >> 8), nil
+	case DMALength:
+		return uint8(d.length), nil
+	case DMALength + 1:
+		return uint8(d.length >> 8), nil
+	}
+	return 0, fmt.Errorf("%w: DMA has no register %d", cpu.BUS_ERROR, offset)
+}
+
+func (d *DMA) Write(offset uint16, value uint8) error {
+	// The transfer happens when it completes, so its registers are left alone
+	// until then
+	if d.status&DMABusy != 0 && offset != DMAStatus && offset < DMASize {
+		return nil
+	}
+
+	switch offset {
+	case DMAControl:
+		d.control = value &^ DMAStart
+		if value&DMAStart != 0 {
+			d.status = DMABusy
+			d.remaining = DMASetupCycles + int(d.length)*DMACyclesPerByte
+		}
+	case DMAStatus:
+		d.status &= DMABusy
+	case DMASource:
+		d.source = d.source&0xFF00 | uint16(value)
+	case DMASource + 1:
+		d.source = d.source&0x00FF | uint16(value)<<8
+	case DMADestination:
+		d.destination = d.destination&0xFF00 | uint16(value)
+	case DMADestination + 1:
+		d.destination = d.destination&0x00FF | uint16(value)<<8
+	case DMALength:
+		d.length = d.length&0xFF00 | uint16(value)
+	case DMALength + 1:
+		d.length = d.length&0x00FF | uint16(value)<<8
+	default:
+		return fmt.Errorf("%w: DMA has no register %d", cpu.BUS_ERROR, offset)
+	}
+	return nil
+}
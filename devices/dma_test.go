@@ -0,0 +1,164 @@
+package devices
+
+import (
+	"slices"
+	"testing"
+
+	"cpu/cpu"
+)
+
+// register is a device with one register that remembers every write to it
+type register struct {
+	writes []uint8
+}
+
+func (r *register) Read(offset uint16) (uint8, error) {
+	return 0, nil
+}
+
+func (r *register) Write(offset uint16, value uint8) error {
+	r.writes = append(r.writes, value)
+	return nil
+}
+
+// startDMA programs a transfer through the registers
+func startDMA(dma *DMA, source, destination, length uint16, control uint8) {
+	dma.Write(DMASource, uint8(source))
+	dma.Write(DMASource+1, uint8(source>>8))
+	dma.Write(DMADestination, uint8(destination))
+	dma.Write(DMADestination+1, uint8(destination>>8))
+	dma.Write(DMALength, uint8(length))
+	dma.Write(DMALength+1, uint8(length>>8))
+	dma.Write(DMAControl, control|DMAStart)
+}
+
+func TestDMACopy(t *testing.T) {
+	mem := cpu.NewMemory(cpu.DefaultLayout)
+	copy(mem.Data[0x1000:], []uint8{1, 2, 3, 4, 5})
+
+	dma := NewDMA(mem)
+	target := &recorder{}
+	dma.SetInterrupt(target, 4)
+	startDMA(dma, 0x1000, 0x2000, 5, DMAInterrupt)
+
+	// Ignored while the transfer is running
+	dma.Write(DMALength, 1)
+
+	dma.Tick(DMASetupCycles + 5*DMACyclesPerByte - 1)
+	if status, _ := dma.Read(DMAStatus); status != DMABusy {
+		t.Errorf("Expected the transfer to still be running, got status %03b", status)
+	}
+	if mem.Data[0x2000] != 0 {
+		t.Errorf("Expected nothing to be copied before the transfer completes")
+	}
+
+	dma.Tick(1)
+	if status, _ := dma.Read(DMAStatus); status != DMADone {
+		t.Errorf("Expected the transfer to be done, got status %03b", status)
+	}
+	if !slices.Equal(mem.Data[0x2000:0x2006], []uint8{1, 2, 3, 4, 5, 0}) {
+		t.Errorf("Expected the block to be copied, got %v", mem.Data[0x2000:0x2006])
+	}
+	if lines := target.raised(); len(lines) != 1 || lines[0] != 4 {
+		t.Errorf("Expected line 4 to be raised, got %v", lines)
+	}
+
+	dma.Write(DMAStatus, 0)
+	if status, _ := dma.Read(DMAStatus); status != 0 {
+		t.Errorf("Expected writing the status to clear it, got %03b", status)
+	}
+}
+
+func TestDMAToDevice(t *testing.T) {
+	mem := cpu.NewMemory(cpu.DefaultLayout)
+	copy(mem.Data[0x1000:], "hey")
+
+	device := &register{}
+	decoder := cpu.NewAddressDecoder()
+	decoder.SetFallback(mem)
+	decoder.Map(0x8000, 1, device)
+	decoder.Map(0x9000, 0x10, cpu.NewROM(nil))
+
+	dma := NewDMA(decoder)
+	startDMA(dma, 0x1000, 0x8000, 3, DMAHoldDestination)
+	dma.Tick(100)
+
+	if string(device.writes) != "hey" {
+		t.Errorf("Expected every byte written to the device register, got %v", device.writes)
+	}
+
+	startDMA(dma, 0x1000, 0x9000, 3, 0)
+	dma.Tick(100)
+	if status, _ := dma.Read(DMAStatus); status != DMADone|DMAError {
+		t.Errorf("Expected a bus error writing ROM, got status %03b", status)
+	}
+}
+
+func TestDMAPorts(t *testing.T) {
+	mem := cpu.NewMemory(cpu.DefaultLayout)
+	copy(mem.Data[0x1000:], "hey")
+
+	c := cpu.NewCPU(cpu.DefaultLayout)
+	device := &register{}
+	c.MapPorts(0x50, 1, device)
+
+	dma := NewDMA(mem)
+	startDMA(dma, 0x1000, 0x50, 3, DMAHoldDestination|DMADestinationPort)
+	dma.Tick(100)
+	if status, _ := dma.Read(DMAStatus); status != DMADone|DMAError {
+		t.Errorf("Expected a bus error without ports, got status %03b", status)
+	}
+
+	dma.SetPorts(c.Ports())
+	startDMA(dma, 0x1000, 0x50, 3, DMAHoldDestination|DMADestinationPort)
+	dma.Tick(100)
+	if string(device.writes) != "hey" {
+		t.Errorf("Expected every byte written to the port, got %v", device.writes)
+	}
+
+	// Reading the port, which is always 0, into memory
+	startDMA(dma, 0x50, 0x1000, 2, DMAHoldSource|DMASourcePort)
+	dma.Tick(100)
+	if !slices.Equal(mem.Data[0x1000:0x1003], []uint8{0, 0, 'y'}) {
+		t.Errorf("Expected the port to be copied into memory, got %v", mem.Data[0x1000:0x1003])
+	}
+
+	startDMA(dma, 0x1000, cpu.PortCount, 1, DMADestinationPort)
+	dma.Tick(100)
+	if status, _ := dma.Read(DMAStatus); status != DMADone|DMAError {
+		t.Errorf("Expected a bus error past the port space, got status %03b", status)
+	}
+}
+
+func TestDMAProgram(t *testing.T) {
+	c, mem := prepCPU(t, []string{
+		"STORE 0x10 a",
+		"STORE 0x11 b",
+		"LOAD R0 0x10",
+		"OUT 2 R0",
+		"LOAD R0 0x20",
+		"OUT 4 R0",
+		"LOAD R0 2",
+		"OUT 6 R0",
+		"LOAD R0 1",
+		"OUT 0 R0",
+		"wait:",
+		"IN R1 1",
+		"AND R1 1",
+		"JNZ wait",
+		"LOADM R2 0x21",
+		"HLT",
+	})
+
+	dma := NewDMA(mem)
+	c.AddTicker(dma)
+	c.MapPorts(0, DMASize, dma)
+
+	if err := c.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if c.Registers[2] != 'b' {
+		t.Errorf("Expected to read the copied byte, got %d", c.Registers[2])
+	}
+}
@@ -0,0 +1,169 @@
+package devices
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"cpu/cpu"
+)
+
+// Pixel display dimensions and registers. Each pixel is one byte holding a
+// colour index, row by row from the top left, followed by the frame register.
+const (
+	PixelWidth        = 64
+	PixelHeight       = 32
+	PixelFrame        = PixelWidth * PixelHeight // Write anything to end a frame
+	PixelDisplaySize  = PixelFrame + 1
+	DefaultPixelsBase = 0xD000
+)
+
+// Palette is the 16 colours of the pixel display, in the same ANSI order as
+// the text display's attributes. Only the low nibble of a pixel is used.
+var Palette = color.Palette{
+	color.RGBA{0x00, 0x00, 0x00, 0xFF},
+	color.RGBA{0x80, 0x00, 0x00, 0xFF},
+	color.RGBA{0x00, 0x80, 0x00, 0xFF},
+	color.RGBA{0x80, 0x80, 0x00, 0xFF},
+	color.RGBA{0x00, 0x00, 0x80, 0xFF},
+	color.RGBA{0x80, 0x00, 0x80, 0xFF},
+	color.RGBA{0x00, 0x80, 0x80, 0xFF},
+	color.RGBA{0xC0, 0xC0, 0xC0, 0xFF},
+	color.RGBA{0x80, 0x80, 0x80, 0xFF},
+	color.RGBA{0xFF, 0x00, 0x00, 0xFF},
+	color.RGBA{0x00, 0xFF, 0x00, 0xFF},
+	color.RGBA{0xFF, 0xFF, 0x00, 0xFF},
+	color.RGBA{0x00, 0x00, 0xFF, 0xFF},
+	color.RGBA{0xFF, 0x00, 0xFF, 0xFF},
+	color.RGBA{0x00, 0xFF, 0xFF, 0xFF},
+	color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+}
+
+// PixelDisplay is an indexed colour bitmap display that can be saved as PNG
+// or PPM, for writing and checking graphics programs without a screen
+type PixelDisplay struct {
+	pixels [PixelWidth * PixelHeight]uint8
+	frames int
+
+	pattern   string
+	every     int
+	snapshots int
+}
+
+func NewPixelDisplay() *PixelDisplay {
+	return &PixelDisplay{}
+}
+
+// Frames returns how many frames the program has ended
+func (d *PixelDisplay) Frames() int {
+	return d.frames
+}
+
+// snapshotVerb matches the %d in a snapshot pattern, which may be padded
+var snapshotVerb = regexp.MustCompile(`%[0-9]*d`)
+
+// SnapshotTo sets where Snapshot saves images. The pattern is a file name
+// with a %d for the snapshot number, and its extension picks PNG or PPM. If
+// every is above 0 a snapshot is also taken every that many frames. An empty
+// pattern turns snapshots off.
+func (d *PixelDisplay) SnapshotTo(pattern string, every int) error {
+	// Other verbs, or a missing %d, would end up garbled in the file name
+	verbs := strings.ReplaceAll(pattern, "%%", "")
+	if pattern != "" && (strings.Count(verbs, "%") != 1 || !snapshotVerb.MatchString(verbs)) {
+		return fmt.Errorf("snapshot pattern %q needs a single %%d for the snapshot number", pattern)
+	}
+	d.pattern = pattern
+	d.every = every
+	return nil
+}
+
+// Snapshot saves the display to the next file named by the snapshot pattern.
+// It does nothing if no pattern is set.
+func (d *PixelDisplay) Snapshot() error {
+	if d.pattern == "" {
+		return nil
+	}
+	path := fmt.Sprintf(d.pattern, d.snapshots)
+	d.snapshots++
+	return d.Save(path)
+}
+
+// Save writes the display to a .png or .ppm file
+func (d *PixelDisplay) Save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		err = d.WritePNG(file)
+	case ".ppm":
+		err = d.WritePPM(file)
+	default:
+		err = fmt.Errorf("unknown image format %q", filepath.Ext(path))
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Image returns a copy of the display as a paletted image
+func (d *PixelDisplay) Image() *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, PixelWidth, PixelHeight), Palette)
+	for i, pixel := range d.pixels {
+		img.Pix[i] = pixel & 0x0F
+	}
+	return img
+}
+
+func (d *PixelDisplay) WritePNG(w io.Writer) error {
+	return png.Encode(w, d.Image())
+}
+
+// WritePPM writes the display as a binary (P6) PPM image
+func (d *PixelDisplay) WritePPM(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "P6\n%d %d\n255\n", PixelWidth, PixelHeight)
+	for _, pixel := range d.pixels {
+		c := Palette[pixel&0x0F].(color.RGBA)
+		bw.Write([]byte{c.R, c.G, c.B})
+	}
+	return bw.Flush()
+}
+
+func (d *PixelDisplay) Read(offset uint16) (uint8, error) {
+	if offset == PixelFrame {
+		return uint8(d.frames), nil
+	}
+	if int(offset) >= len(d.pixels) {
+		return 0, fmt.Errorf("%w: read at offset %d", cpu.MEMORY_OUT_OF_BOUNDS, offset)
+	}
+	return d.pixels[offset], nil
+}
+
+func (d *PixelDisplay) Write(offset uint16, value uint8) error {
+	if offset == PixelFrame {
+		d.frames++
+		if d.every > 0 && d.frames%d.every == 0 {
+			if err := d.Snapshot(); err != nil {
+				return fmt.Errorf("%w: snapshot: %v", cpu.IO_ERROR, err)
+			}
+		}
+		return nil
+	}
+	if int(offset) >= len(d.pixels) {
+		return fmt.Errorf("%w: write at offset %d", cpu.MEMORY_OUT_OF_BOUNDS, offset)
+	}
+	d.pixels[offset] = value
+	return nil
+}
@@ -0,0 +1,115 @@
+package devices
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cpu/cpu"
+)
+
+func TestPixelDisplaySnapshots(t *testing.T) {
+	c, mem := prepCPU(t, []string{
+		"STORE 0xD000 15",
+		"STORE 0xD041 9",
+		"SNAP",
+		"STORE 0xD800 1",
+		"STORE 0xD800 1",
+		"STORE 0xD7FF 0x1C",
+		"HLT",
+	})
+
+	dir := t.TempDir()
+	display := NewPixelDisplay()
+	if err := display.SnapshotTo(filepath.Join(dir, "snap-%d.png"), 2); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	c.AddSnapshotter(display)
+
+	decoder := cpu.NewAddressDecoder()
+	decoder.SetFallback(mem)
+	decoder.Map(DefaultPixelsBase, PixelDisplaySize, display)
+
+	if err := c.Execute(decoder); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if display.Frames() != 2 {
+		t.Errorf("Expected 2 frames, got %d", display.Frames())
+	}
+
+	// One snapshot from SNAP and one from the second frame
+	expected := map[[2]int]int{{0, 0}: 15, {1, 1}: 9, {63, 31}: 0}
+	for i := range 2 {
+		file, err := os.Open(filepath.Join(dir, fmt.Sprintf("snap-%d.png", i)))
+		if err != nil {
+			t.Fatalf("Expected snapshot %d: %s", i, err)
+		}
+		img, err := png.Decode(file)
+		file.Close()
+		if err != nil {
+			t.Fatalf("Unexpected error decoding snapshot %d: %s", i, err)
+		}
+
+		for point, colour := range expected {
+			if img.At(point[0], point[1]) != Palette[colour] {
+				t.Errorf("Expected colour %d at %v in snapshot %d, got %v", colour, point, i, img.At(point[0], point[1]))
+			}
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "snap-2.png")); err == nil {
+		t.Errorf("Expected only 2 snapshots")
+	}
+
+	// The last write only shows up in the display itself, using the low nibble
+	if display.Image().ColorIndexAt(63, 31) != 12 {
+		t.Errorf("Expected colour 12 at the bottom right, got %d", display.Image().ColorIndexAt(63, 31))
+	}
+}
+
+func TestPixelDisplaySnapshotPattern(t *testing.T) {
+	display := NewPixelDisplay()
+
+	for _, pattern := range []string{"out.png", "out-%s.png", "out-%d-%d.png", "100%%-%x.png"} {
+		if err := display.SnapshotTo(pattern, 0); err == nil {
+			t.Errorf("Expected pattern %q to be rejected", pattern)
+		}
+	}
+	for _, pattern := range []string{"", "out-%d.png", "frame-%03d.png", "100%%-%d.ppm"} {
+		if err := display.SnapshotTo(pattern, 0); err != nil {
+			t.Errorf("Expected pattern %q to be accepted, got %v", pattern, err)
+		}
+	}
+}
+
+func TestPixelDisplayPPM(t *testing.T) {
+	display := NewPixelDisplay()
+	display.Write(1, 9)
+
+	var output bytes.Buffer
+	if err := display.WritePPM(&output); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	header := "P6\n64 32\n255\n"
+	if !bytes.HasPrefix(output.Bytes(), []byte(header)) {
+		t.Fatalf("Expected a P6 header, got %q", output.Bytes()[:len(header)])
+	}
+
+	pixels := output.Bytes()[len(header):]
+	if len(pixels) != PixelWidth*PixelHeight*3 {
+		t.Fatalf("Expected %d bytes of pixels, got %d", PixelWidth*PixelHeight*3, len(pixels))
+	}
+
+	if !bytes.Equal(pixels[:6], []byte{0, 0, 0, 0xFF, 0, 0}) {
+		t.Errorf("Expected black then bright red, got %v", pixels[:6])
+	}
+
+	if err := display.Save(filepath.Join(t.TempDir(), "snap.gif")); err == nil {
+		t.Errorf("Expected saving an unknown format to fail")
+	}
+}
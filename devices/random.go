@@ -0,0 +1,67 @@
+package devices
+
+import (
+	"fmt"
+
+	"cpu/cpu"
+)
+
+// Random register offsets
+const (
+	RandomData = 0 // Read the next random byte
+	RandomSeed = 1 // Seed, 4 bytes low byte first. Writing any byte reseeds
+	RandomSize = 5
+)
+
+// Random is a seedable pseudo-random number generator, so a run can be
+// repeated exactly by reusing its seed. It uses xorshift32, which is fixed
+// here rather than depending on the Go version.
+type Random struct {
+	seed  uint32
+	state uint32
+}
+
+func NewRandom(seed uint32) *Random {
+	r := &Random{}
+	r.Seed(seed)
+	return r
+}
+
+// Seed restarts the sequence from seed
+func (r *Random) Seed(seed uint32) {
+	r.seed = seed
+	r.state = seed
+	// xorshift never leaves zero
+	if r.state == 0 {
+		r.state = 0x9E3779B9
+	}
+}
+
+func (r *Random) next() uint8 {
+	r.state ^= r.state << 13
+	r.state ^= r.state >> 17
+	r.state ^= r.state << 5
+	return uint8(r.state >> 24)
+}
+
+func (r *Random) Read(offset uint16) (uint8, error) {
+	switch {
+	case offset == RandomData:
+		return r.next(), nil
+	case offset >= RandomSeed && offset < RandomSize:
+		return uint8(r.seed >> (8 * (offset - RandomSeed))), nil
+	}
+	return 0, fmt.Errorf("%w: random has no register %d", cpu.BUS_ERROR, offset)
+}
+
+func (r *Random) Write(offset uint16, value uint8) error {
+	switch {
+	case offset == RandomData:
+		return nil
+	case offset >= RandomSeed && offset < RandomSize:
+		shift := 8 * (offset - RandomSeed)
+		r.Seed(r.seed&^(0xFF<<shift) | uint32(value)<<shift)
+		return nil
+	}
+	return fmt.Errorf("%w: random has no register %d", cpu.BUS_ERROR, offset)
+}
@@ -0,0 +1,69 @@
+package devices
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRandomSequence(t *testing.T) {
+	read := func(r *Random, n int) []uint8 {
+		var values []uint8
+		for range n {
+			value, _ := r.Read(RandomData)
+			values = append(values, value)
+		}
+		return values
+	}
+
+	first := read(NewRandom(1234), 16)
+	if !slices.Equal(first, read(NewRandom(1234), 16)) {
+		t.Errorf("Expected the same seed to give the same sequence")
+	}
+
+	if slices.Equal(first, read(NewRandom(1235), 16)) {
+		t.Errorf("Expected a different seed to give a different sequence")
+	}
+
+	if slices.Equal(first[:8], first[8:]) {
+		t.Errorf("Expected the sequence not to repeat, got %v", first)
+	}
+
+	// Reseeding from a program restarts the sequence
+	r := NewRandom(0)
+	read(r, 3)
+	for i, b := range []uint8{0xD2, 0x04, 0, 0} {
+		r.Write(RandomSeed+uint16(i), b)
+	}
+	if !slices.Equal(read(r, 16), first) {
+		t.Errorf("Expected writing the seed 1234 to restart its sequence")
+	}
+
+	if seed, _ := r.Read(RandomSeed + 1); seed != 0x04 {
+		t.Errorf("Expected to read back the seed, got %d", seed)
+	}
+
+	zero := read(NewRandom(0), 4)
+	if slices.Equal(zero, []uint8{0, 0, 0, 0}) {
+		t.Errorf("Expected a zero seed to still give random values")
+	}
+}
+
+func TestRandomProgram(t *testing.T) {
+	c, mem := prepCPU(t, []string{
+		"IN R0 0",
+		"IN R1 0",
+		"HLT",
+	})
+	c.MapPorts(0, RandomSize, NewRandom(7))
+
+	if err := c.Execute(mem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := NewRandom(7)
+	r0, _ := expected.Read(RandomData)
+	r1, _ := expected.Read(RandomData)
+	if c.Registers[0] != r0 || c.Registers[1] != r1 {
+		t.Errorf("Expected %d and %d, got %d and %d", r0, r1, c.Registers[0], c.Registers[1])
+	}
+}
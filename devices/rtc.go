@@ -0,0 +1,93 @@
+package devices
+
+import (
+	"fmt"
+	"time"
+
+	"cpu/cpu"
+)
+
+// RTC register offsets. Reading the second latches the current time, and the
+// other fields read from the latched time, so they all agree.
+const (
+	RTCSecond  = 0
+	RTCMinute  = 1
+	RTCHour    = 2
+	RTCDay     = 3 // Day of the month, from 1
+	RTCMonth   = 4 // From 1
+	RTCYearLo  = 5
+	RTCYearHi  = 6
+	RTCWeekday = 7 // Sunday is 0
+	RTCSize    = 8
+)
+
+// RTC is a real-time clock device. It either follows the host clock, or for
+// reproducible runs starts from a fixed time and advances with emulated cycles.
+type RTC struct {
+	now     func() time.Time
+	latched time.Time
+
+	// Fixed clock mode
+	start   time.Time
+	clockHz int
+	cycles  uint64
+}
+
+// NewRTC creates a clock following the host's local time
+func NewRTC() *RTC {
+	return &RTC{now: time.Now}
+}
+
+// NewFixedRTC creates a clock starting at start that advances with emulated
+// time, for a CPU running clockHz cycles per second. Add it to the CPU with
+// AddTicker.
+func NewFixedRTC(start time.Time, clockHz int) *RTC {
+	r := &RTC{start: start, clockHz: clockHz}
+	r.now = r.emulated
+	return r
+}
+
+// emulated converts whole seconds and the cycles left over separately, since
+// scaling all the cycles to nanoseconds overflows after a few hours at MHz
+// clock rates
+func (r *RTC) emulated() time.Time {
+	hz := uint64(r.clockHz)
+	seconds := time.Duration(r.cycles/hz) * time.Second
+	fraction := time.Duration(r.cycles%hz) * time.Second / time.Duration(hz)
+	return r.start.Add(seconds + fraction)
+}
+
+func (r *RTC) Tick(cycles int) {
+	r.cycles += uint64(cycles)
+}
+
+func (r *RTC) Read(offset uint16) (uint8, error) {
+	switch offset {
+	case RTCSecond:
+		r.latched = r.now()
+		return uint8(r.latched.Second()), nil
+	case RTCMinute:
+		return uint8(r.latched.Minute()), nil
+	case RTCHour:
+		return uint8(r.latched.Hour()), nil
+	case RTCDay:
+		return uint8(r.latched.Day()), nil
+	case RTCMonth:
+		return uint8(r.latched.Month()), nil
+	case RTCYearLo:
+		return uint8(r.latched.Year()), nil
+	case RTCYearHi:
+		return uint8(r.latched.Year() >> 8), nil
+	case RTCWeekday:
+		return uint8(r.latched.Weekday()), nil
+	}
+	return 0, fmt.Errorf("%w: clock has no register %d", cpu.BUS_ERROR, offset)
+}
+
+// Write does nothing, the clock cannot be set by programs
+func (r *RTC) Write(offset uint16, value uint8) error {
+	if offset >= RTCSize {
+		return fmt.Errorf("%w: clock has no register %d", cpu.BUS_ERROR, offset)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package devices
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFixedRTC(t *testing.T) {
+	start := time.Date(2024, time.February, 29, 23, 59, 58, 0, time.UTC)
+	rtc := NewFixedRTC(start, 1000)
+
+	read := func() []uint8 {
+		var fields []uint8
+		for offset := range uint16(RTCSize) {
+			value, _ := rtc.Read(offset)
+			fields = append(fields, value)
+		}
+		return fields
+	}
+
+	expected := []uint8{58, 59, 23, 29, 2, 0xE8, 0x07, uint8(time.Thursday)}
+	for i, value := range read() {
+		if value != expected[i] {
+			t.Errorf("Expected field %d to be %d, got %d", i, expected[i], value)
+		}
+	}
+
+	// Two and a half emulated seconds later it is the next day
+	rtc.Tick(2500)
+	expected = []uint8{0, 0, 0, 1, 3, 0xE8, 0x07, uint8(time.Friday)}
+	for i, value := range read() {
+		if value != expected[i] {
+			t.Errorf("Expected field %d to be %d after ticking, got %d", i, expected[i], value)
+		}
+	}
+}
+
+func TestFixedRTCLongRun(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	rtc := NewFixedRTC(start, 4_000_000)
+
+	// A day at 4 MHz, far more cycles than fit in nanoseconds
+	for range 24 {
+		rtc.Tick(4_000_000 * 3600)
+	}
+	rtc.Tick(3_000_000)
+
+	if got, want := rtc.emulated(), start.Add(24*time.Hour+750*time.Millisecond); !got.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestRTCLatch(t *testing.T) {
+	rtc := NewFixedRTC(time.Date(2024, time.January, 1, 0, 0, 59, 0, time.UTC), 1)
+
+	rtc.Read(RTCSecond)
+	rtc.Tick(1)
+
+	if minute, _ := rtc.Read(RTCMinute); minute != 0 {
+		t.Errorf("Expected the minute from the latched time, got %d", minute)
+	}
+
+	if second, _ := rtc.Read(RTCSecond); second != 0 {
+		t.Errorf("Expected reading the second to latch the new time, got %d", second)
+	}
+
+	if minute, _ := rtc.Read(RTCMinute); minute != 1 {
+		t.Errorf("Expected the minute to follow the new latch, got %d", minute)
+	}
+}
+
+func TestHostRTC(t *testing.T) {
+	rtc := NewRTC()
+	rtc.Read(RTCSecond)
+	lo, _ := rtc.Read(RTCYearLo)
+	hi, _ := rtc.Read(RTCYearHi)
+
+	if year := int(hi)<<8 | int(lo); year != time.Now().Year() {
+		t.Errorf("Expected the host's year %d, got %d", time.Now().Year(), year)
+	}
+}
@@ -0,0 +1,130 @@
+package devices
+
+import (
+	"fmt"
+
+	"cpu/cpu"
+)
+
+// Timer register offsets
+const (
+	TimerControl   = 0 // Control bits
+	TimerStatus    = 1 // Status bits, write anything to clear
+	TimerReloadLo  = 2 // Low byte of the value counted down from
+	TimerReloadHi  = 3 // High byte of the value counted down from
+	TimerCountLo   = 4 // Low byte of the current count, read only
+	TimerCountHi   = 5 // High byte of the current count, read only
+	TimerPrescaler = 6 // The count goes down once every prescaler + 1 cycles
+	TimerSize      = 7
+)
+
+// Timer control bits
+const (
+	TimerEnable    = 1 << 0 // Count down, loading the reload value when set
+	TimerInterrupt = 1 << 1 // Raise the timer's interrupt on expiry
+	TimerPeriodic  = 1 << 2 // Start again from the reload value on expiry instead of stopping
+)
+
+// Timer status bits
+const (
+	TimerExpired = 1 << 0 // The count has reached zero since the status was cleared
+)
+
+// Timer is a programmable countdown timer driven by the CPU's cycle counter,
+// so it expires after the same number of instructions on every run. Add it to
+// the CPU with AddTicker. A reload value of 0 counts 65536.
+type Timer struct {
+	control   uint8
+	status    uint8
+	reload    uint16
+	count     uint16
+	prescaler uint8
+	prescale  uint8
+	irq       irq
+}
+
+func NewTimer() *Timer {
+	return &Timer{}
+}
+
+// SetInterrupt makes the timer raise line on target when it expires and
+// TimerInterrupt is set
+func (t *Timer) SetInterrupt(target Interrupter, line uint8) {
+	t.irq = irq{target, line}
+}
+
+func (t *Timer) Tick(cycles int) {
+	for range cycles {
+		if t.control&TimerEnable == 0 {
+			return
+		}
+
+		if t.prescale < t.prescaler {
+			t.prescale++
+			continue
+		}
+		t.prescale = 0
+
+		t.count--
+		if t.count == 0 {
+			t.expire()
+		}
+	}
+}
+
+func (t *Timer) expire() {
+	t.status |= TimerExpired
+	if t.control&TimerInterrupt != 0 {
+		t.irq.raise()
+	}
+
+	if t.control&TimerPeriodic != 0 {
+		t.count = t.reload
+	} else {
+		t.control &^= TimerEnable
+	}
+}
+
+func (t *Timer) Read(offset uint16) (uint8, error) {
+	switch offset {
+	case TimerControl:
+		return t.control, nil
+	case TimerStatus:
+		return t.status, nil
+	case TimerReloadLo:
+		return uint8(t.reload), nil
+	case TimerReloadHi:
+		return uint8(t.reload >> 8), nil
+	case TimerCountLo:
+		return uint8(t.count), nil
+	case TimerCountHi:
+		return uint8(t.count >> 8), nil
+	case TimerPrescaler:
+		return t.prescaler, nil
+	}
+	return 0, fmt.Errorf("%w: timer has no register %d", cpu.BUS_ERROR, offset)
+}
+
+func (t *Timer) Write(offset uint16, value uint8) error {
+	switch offset {
+	case TimerControl:
+		// Starting the timer loads the reload value
+		if t.control&TimerEnable == 0 && value&TimerEnable != 0 {
+			t.count = t.reload
+			t.prescale = 0
+		}
+		t.control = value
+	case TimerStatus:
+		t.status = 0
+	case TimerReloadLo:
+		t.reload = t.reload&0xFF00 | uint16(value)
+	case TimerReloadHi:
+		t.reload = t.reload&0x00FF | uint16(value)<<8
+	case TimerCountLo, TimerCountHi:
+	case TimerPrescaler:
+		t.prescaler = value
+	default:
+		return fmt.Errorf("%w: timer has no register %d", cpu.BUS_ERROR, offset)
+	}
+	return nil
+}
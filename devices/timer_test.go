@@ -0,0 +1,108 @@
+package devices
+
+import (
+	"testing"
+
+	"cpu/cpu"
+)
+
+func TestTimerOneShot(t *testing.T) {
+	timer := NewTimer()
+	timer.Write(TimerReloadLo, 3)
+	timer.Write(TimerPrescaler, 1)
+	timer.Write(TimerControl, TimerEnable)
+
+	timer.Tick(5)
+	if status, _ := timer.Read(TimerStatus); status != 0 {
+		t.Errorf("Expected the timer not to have expired after 5 cycles")
+	}
+	if count, _ := timer.Read(TimerCountLo); count != 1 {
+		t.Errorf("Expected a count of 1, got %d", count)
+	}
+
+	timer.Tick(1)
+	if status, _ := timer.Read(TimerStatus); status != TimerExpired {
+		t.Errorf("Expected the timer to expire after 6 cycles")
+	}
+	if control, _ := timer.Read(TimerControl); control&TimerEnable != 0 {
+		t.Errorf("Expected a one shot timer to stop on expiry")
+	}
+
+	timer.Write(TimerStatus, 0)
+	if status, _ := timer.Read(TimerStatus); status != 0 {
+		t.Errorf("Expected writing the status to clear it, got %d", status)
+	}
+}
+
+func TestTimerPeriodicInterrupt(t *testing.T) {
+	timer := NewTimer()
+	target := &recorder{}
+	timer.SetInterrupt(target, 1)
+	timer.Write(TimerReloadLo, 0x2C)
+	timer.Write(TimerReloadHi, 0x01)
+	timer.Write(TimerControl, TimerEnable|TimerInterrupt|TimerPeriodic)
+
+	timer.Tick(300 * 3)
+	if lines := target.raised(); len(lines) != 3 {
+		t.Errorf("Expected 3 interrupts after 900 cycles, got %v", lines)
+	}
+
+	if count, _ := timer.Read(TimerCountHi); count != 0x01 {
+		t.Errorf("Expected the count to be reloaded, got high byte %d", count)
+	}
+}
+
+func TestTimerProgram(t *testing.T) {
+	run := func() (*cpu.CPU, error) {
+		c, mem := prepCPU(t, []string{
+			"STORE 0xFEE4 <tick",
+			"STORE 0xFEE5 >tick",
+			"LOAD R0 20",
+			"OUT 8 R0",
+			"LOAD R0 5",
+			"OUT 4 R0",
+			"LOAD R0 7",
+			"OUT 2 R0",
+			"EI",
+			"wait:",
+			"CMP R3 3",
+			"JL wait",
+			"HLT",
+			"tick:",
+			"INC R3",
+			"IRET",
+		})
+
+		timer := NewTimer()
+		timer.SetInterrupt(c, 2)
+		c.AddTicker(timer)
+		if err := c.MapPorts(2, TimerSize, timer); err != nil {
+			t.Fatalf("Unexpected error mapping the timer: %s", err)
+		}
+
+		return c, c.Execute(mem)
+	}
+
+	first, err := run()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if first.Registers[3] != 3 {
+		t.Errorf("Expected the handler to run 3 times, got %d", first.Registers[3])
+	}
+
+	// 3 expiries of 5 * 21 cycles each happen before the program can halt
+	if first.Cycles < 3*5*21 {
+		t.Errorf("Expected at least %d cycles, got %d", 3*5*21, first.Cycles)
+	}
+
+	second, err := run()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if first.Cycles != second.Cycles {
+		t.Errorf("Expected identical runs, took %d and %d cycles", first.Cycles, second.Cycles)
+	}
+}
@@ -0,0 +1,24 @@
+# Code and data placed in banks are switched into the bank window when used.
+# Compile with -bank-size 0x100 to give the layout a window at 0x8000.
+FARCALL greet
+HLT
+
+.bank greetings
+greet:
+  # Point the data bank at each message in turn, which sit at the start of
+  # the window in their banks
+  LOAD R0 ^english
+  OUT 49 R0
+  PRINTS 0x8000
+  LOAD R0 ^french
+  OUT 49 R0
+  PRINTS 0x8000
+  RET
+
+.bank english
+english:
+.byte H e l l o , 32 w o r l d ! 10 0
+
+.bank french
+french:
+.byte B o n j o u r , 32 l e 32 m o n d e ! 10 0
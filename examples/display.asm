@@ -0,0 +1,60 @@
+# Writes to the text display, run with -display
+# Each cell is a character followed by an attribute, whose low nibble is the
+# foreground colour and high nibble the background colour
+
+# Hello, World! in bright white on blue on the first row
+STORE 0xE000 H
+STORE 0xE001 0x4F
+STORE 0xE002 e
+STORE 0xE003 0x4F
+STORE 0xE004 l
+STORE 0xE005 0x4F
+STORE 0xE006 l
+STORE 0xE007 0x4F
+STORE 0xE008 o
+STORE 0xE009 0x4F
+STORE 0xE00A ,
+STORE 0xE00B 0x4F
+STORE 0xE00C 32
+STORE 0xE00D 0x4F
+STORE 0xE00E W
+STORE 0xE00F 0x4F
+STORE 0xE010 o
+STORE 0xE011 0x4F
+STORE 0xE012 r
+STORE 0xE013 0x4F
+STORE 0xE014 l
+STORE 0xE015 0x4F
+STORE 0xE016 d
+STORE 0xE017 0x4F
+STORE 0xE018 !
+STORE 0xE019 0x4F
+
+# A green line under it
+STORE 0xE050 -
+STORE 0xE051 0x02
+STORE 0xE052 -
+STORE 0xE053 0x02
+STORE 0xE054 -
+STORE 0xE055 0x02
+STORE 0xE056 -
+STORE 0xE057 0x02
+STORE 0xE058 -
+STORE 0xE059 0x02
+STORE 0xE05A -
+STORE 0xE05B 0x02
+STORE 0xE05C -
+STORE 0xE05D 0x02
+STORE 0xE05E -
+STORE 0xE05F 0x02
+STORE 0xE060 -
+STORE 0xE061 0x02
+STORE 0xE062 -
+STORE 0xE063 0x02
+STORE 0xE064 -
+STORE 0xE065 0x02
+STORE 0xE066 -
+STORE 0xE067 0x02
+STORE 0xE068 -
+STORE 0xE069 0x02
+HLT
@@ -0,0 +1,17 @@
+# Echoes input back through the console device
+# Run with -console, the console is on ports 0 (data) and 1 (status)
+loop:
+  IN R0 1
+  LOAD R1 R0
+  # Stop once the input has ended
+  AND R1 4
+  JNZ done
+  # Wait until a byte is ready
+  AND R0 1
+  JZ loop
+  IN R2 0
+  OUT 0 R2
+  JMP loop
+
+done:
+  HLT
@@ -1,51 +1,144 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 
 	"cpu/cpu"
+	"cpu/devices"
+)
+
+// Ports and interrupt lines of the devices attached from the command line
+const (
+	consolePort = 0x00
+	consoleIRQ  = 0
+	timerPort   = 0x04
+	timerIRQ    = 1
+	diskPort    = 0x0C
+	beeperPort  = 0x14
+	randomPort  = 0x1A
+	rtcPort     = 0x20
+	dmaPort     = 0x28
+	dmaIRQ      = 2
+
+	// Cycles between redraws of the text display
+	displayRefresh = 10000
 )
 
 func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run compiles or runs the file named by the flags. It returns errors rather
+// than exiting, so that deferred cleanup such as closing the disk image runs.
+func run() error {
 	// Define a flag for the file name
 	fileName := flag.String("f", "", "Path to the file to be loaded")
 	toCompile := flag.Bool("c", false, "Compile the file")
 	outputFileName := flag.String("o", "", "Output file name")
 	toRun := flag.Bool("r", false, "Run the compiled file")
+	maxSteps := flag.Int("max-steps", 0, "Maximum instructions to run (0 for no limit)")
+	timeout := flag.Duration("timeout", 0, "Maximum wall time to run for (0 for no limit)")
+	maxOutput := flag.Int("max-output", 0, "Maximum bytes the program may print (0 for no limit)")
+	maxStack := flag.Int("max-stack", 0, "Maximum stack depth (0 for no limit)")
+	selfModifying := flag.Bool("self-modifying", false, "Allow the program to write to its code and execute data")
+	dataSize := flag.Int("data-size", cpu.DefaultLayout.DataSize, "Bytes of stored memory from address 0")
+	codeOrigin := flag.Uint("code-origin", uint(cpu.DefaultLayout.CodeOrigin), "Address code is loaded at and run from")
+	memorySize := flag.Int("memory-size", cpu.DefaultLayout.TotalSize, "Bytes of memory")
+	stackTop := flag.Uint("stack-top", uint(cpu.DefaultLayout.StackTop), "Address the stack grows down from")
+	stackSize := flag.Int("stack-size", cpu.DefaultLayout.StackSize, "Bytes the stack may grow to")
+	bankWindow := flag.Uint("bank-window", 0x8000, "Address of the bank window")
+	bankSize := flag.Int("bank-size", cpu.DefaultLayout.BankSize, "Bytes in the bank window and each bank (0 for no banks)")
+	bankCount := flag.Int("banks", 0, "Banks to attach, at least as many as the program uses")
+	console := flag.Bool("console", false, "Attach a console device on ports 0-2 using stdin and stdout")
+	timer := flag.Bool("timer", false, "Attach a timer device on ports 4-10")
+	diskImage := flag.String("disk", "", "Attach a disk device on ports 12-19 backed by this image, created if missing")
+	diskReadOnly := flag.Bool("disk-readonly", false, "Mount the disk image read only")
+	display := flag.Bool("display", false, "Attach a 40x25 text display at 0xE000 drawn on the terminal")
+	pixels := flag.Bool("pixels", false, "Attach a 64x32 pixel display at 0xD000")
+	snapPattern := flag.String("snap", "", "Pixel display snapshot files, with %d for the number (.png or .ppm)")
+	snapEvery := flag.Int("snap-every", 0, "Also snapshot the pixel display every N frames (0 for never)")
+	beeperOutput := flag.String("beeper", "", "Attach a beeper on ports 20-25 and write its audio to this WAV file")
+	random := flag.Bool("random", false, "Attach a random number generator on ports 26-30")
+	randomSeed := flag.Uint("random-seed", 0, "Seed for the random number generator (0 to seed from the clock)")
+	rtc := flag.Bool("rtc", false, "Attach a real-time clock on ports 32-39")
+	rtcStart := flag.String("rtc-start", "", "Start the clock at this RFC 3339 time and advance it with emulated time")
+	dma := flag.Bool("dma", false, "Attach a DMA controller on ports 40-47")
+	mmu := flag.Bool("mmu", false, "Place a paging MMU controlled from ports 52-57 between the CPU and memory")
 
 	// Parse the flags
 	flag.Parse()
 
 	if !*toCompile && !*toRun {
-		log.Fatal("Please provide a flag to either compile or run the file")
+		return errors.New("Please provide a flag to either compile or run the file")
 	}
 
 	if *toCompile && *toRun {
-		log.Fatal("Please provide only one flag to either compile or run the file")
+		return errors.New("Please provide only one flag to either compile or run the file")
 	}
 
 	if *fileName == "" {
-		log.Fatal("Please provide a file name using the -f flag")
+		return errors.New("Please provide a file name using the -f flag")
+	}
+
+	if *codeOrigin > 0xFFFF || *stackTop > 0xFFFF || *bankWindow > 0xFFFF {
+		return errors.New("Please provide addresses from 0 to 65535")
+	}
+
+	// Layout flags set the layout when compiling, and override the one stored
+	// in the binary when running
+	applyLayoutFlags := func(layout cpu.Layout) (cpu.Layout, error) {
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "data-size":
+				layout.DataSize = *dataSize
+			case "code-origin":
+				layout.CodeOrigin = uint16(*codeOrigin)
+			case "memory-size":
+				layout.TotalSize = *memorySize
+			case "stack-top":
+				layout.StackTop = uint16(*stackTop)
+			case "stack-size":
+				layout.StackSize = *stackSize
+			case "bank-window", "bank-size":
+				layout.BankWindow = uint16(*bankWindow)
+				layout.BankSize = *bankSize
+			}
+		})
+		if err := layout.Validate(); err != nil {
+			return layout, fmt.Errorf("Invalid memory layout: %w", err)
+		}
+		return layout, nil
 	}
 
 	if *toCompile {
 		// Open and read the file
 		data, err := os.ReadFile(*fileName)
 		if err != nil {
-			log.Fatalf("Failed to read file: %v", err)
+			return fmt.Errorf("Failed to read file: %w", err)
 		}
 
 		// Split the file contents by new line
 		lines := strings.Split(string(data), "\n")
 
-		asm := cpu.NewAssembler(lines)
+		layout, err := applyLayoutFlags(cpu.DefaultLayout)
+		if err != nil {
+			return err
+		}
+		asm := cpu.NewAssembler(lines, layout)
 
-		bytecode, err := asm.Assemble()
+		program, err := asm.AssembleProgram()
 		if err != nil {
-			log.Fatalf("Failed to assemble code: %v", err)
+			return fmt.Errorf("Failed to assemble code: %w", err)
 		}
 
 		outputFileName := *outputFileName
@@ -53,28 +146,224 @@ func main() {
 			outputFileName = *fileName + ".bin"
 		}
 
-		err = os.WriteFile(outputFileName, bytecode, 0644)
+		err = os.WriteFile(outputFileName, cpu.EncodeBinary(program), 0644)
 		if err != nil {
-			log.Fatalf("Failed to write file: %v", err)
+			return fmt.Errorf("Failed to write file: %w", err)
 		}
 
 		log.Printf("File compiled successfully: %s", outputFileName)
 
-		return
+		return nil
 	}
 
 	if *toRun {
-		bytecode, err := os.ReadFile(*fileName)
+		data, err := os.ReadFile(*fileName)
+		if err != nil {
+			return fmt.Errorf("Failed to read file: %w", err)
+		}
+
+		program, err := cpu.DecodeBinary(data)
+		if err != nil {
+			return fmt.Errorf("Failed to load binary: %w", err)
+		}
+		// Labels were resolved against the code origin and bank window, so
+		// neither can move
+		assembled := program.Layout
+		layout, err := applyLayoutFlags(assembled)
+		if err != nil {
+			return err
+		}
+		if layout.CodeOrigin != assembled.CodeOrigin {
+			return fmt.Errorf("The binary was assembled for code origin %d, recompile it to move the code", assembled.CodeOrigin)
+		}
+		if len(program.Banks) > 0 && (layout.BankWindow != assembled.BankWindow || layout.BankSize != assembled.BankSize) {
+			return fmt.Errorf("The binary was assembled for a %d byte bank window at %d, recompile it to move the banks", assembled.BankSize, assembled.BankWindow)
+		}
+
+		cpuInstance := cpu.NewCPU(layout)
+		if err := cpuInstance.MapPorts(cpu.SystemPort, cpu.SystemRegistersSize, cpuInstance.SystemRegisters()); err != nil {
+			return fmt.Errorf("Failed to attach system registers: %w", err)
+		}
+		memory := cpu.NewMemory(layout)
+		if err := memory.LoadCode(program.Code); err != nil {
+			return fmt.Errorf("Failed to load binary: %w", err)
+		}
+		memory.SelfModifying = *selfModifying
+
+		// Memory-mapped devices are placed over plain memory
+		bus := cpu.NewAddressDecoder()
+		bus.SetFallback(memory)
+
+		if *bankCount > 0 && !layout.Banked() {
+			return errors.New("Please provide a bank window with -bank-size to attach banks")
+		}
+		if layout.Banked() {
+			count := max(*bankCount, len(program.Banks), 1)
+			if count > cpu.MaxBanks {
+				return fmt.Errorf("Please provide at most %d banks", cpu.MaxBanks)
+			}
+			banks := cpu.NewBanks(count, layout.BankSize)
+			if err := banks.Load(program.Banks); err != nil {
+				return fmt.Errorf("Failed to load banks: %w", err)
+			}
+			if err := bus.Map(layout.BankWindow, layout.BankSize, banks); err != nil {
+				return fmt.Errorf("Failed to attach banks: %w", err)
+			}
+			if err := cpuInstance.MapPorts(cpu.BankPort, cpu.BankRegistersSize, banks.Registers()); err != nil {
+				return fmt.Errorf("Failed to attach bank registers: %w", err)
+			}
+		}
+
+		if *console {
+			consoleDevice := devices.NewConsole(os.Stdin, os.Stdout)
+			// The console reads stdin in the background, so READ gets no input
+			// rather than racing it for bytes
+			cpuInstance.SetInput(strings.NewReader(""))
+			consoleDevice.SetInterrupt(cpuInstance, consoleIRQ)
+			if err := cpuInstance.MapPorts(consolePort, devices.ConsoleSize, consoleDevice); err != nil {
+				return fmt.Errorf("Failed to attach console: %w", err)
+			}
+		}
+
+		if *timer {
+			timerDevice := devices.NewTimer()
+			timerDevice.SetInterrupt(cpuInstance, timerIRQ)
+			cpuInstance.AddTicker(timerDevice)
+			if err := cpuInstance.MapPorts(timerPort, devices.TimerSize, timerDevice); err != nil {
+				return fmt.Errorf("Failed to attach timer: %w", err)
+			}
+		}
+
+		if *diskImage != "" {
+			diskDevice, err := devices.OpenDisk(*diskImage, bus, devices.DiskOptions{
+				ReadOnly: *diskReadOnly,
+				Create:   true,
+			})
+			if err != nil {
+				return fmt.Errorf("Failed to open disk image: %w", err)
+			}
+			defer diskDevice.Close()
+			if err := cpuInstance.MapPorts(diskPort, devices.DiskSize, diskDevice); err != nil {
+				return fmt.Errorf("Failed to attach disk: %w", err)
+			}
+		}
+
+		var displayDevice *devices.TextDisplay
+		if *display {
+			displayDevice = devices.NewTextDisplay()
+			displayDevice.RenderTo(os.Stdout, displayRefresh)
+			cpuInstance.AddTicker(displayDevice)
+			if err := bus.Map(devices.DefaultTextDisplayBase, devices.TextDisplaySize, displayDevice); err != nil {
+				return fmt.Errorf("Failed to attach display: %w", err)
+			}
+			// Start from a clear screen
+			os.Stdout.WriteString("\x1b[2J")
+		}
+
+		var pixelDevice *devices.PixelDisplay
+		if *pixels {
+			pixelDevice = devices.NewPixelDisplay()
+			if err := pixelDevice.SnapshotTo(*snapPattern, *snapEvery); err != nil {
+				return fmt.Errorf("Invalid snapshot pattern: %w", err)
+			}
+			cpuInstance.AddSnapshotter(pixelDevice)
+			if err := bus.Map(devices.DefaultPixelsBase, devices.PixelDisplaySize, pixelDevice); err != nil {
+				return fmt.Errorf("Failed to attach pixel display: %w", err)
+			}
+		}
+
+		var beeperDevice *devices.Beeper
+		if *beeperOutput != "" {
+			beeperDevice = devices.NewBeeper(devices.DefaultClockHz, devices.DefaultSampleRate)
+			cpuInstance.AddTicker(beeperDevice)
+			if err := cpuInstance.MapPorts(beeperPort, devices.BeeperSize, beeperDevice); err != nil {
+				return fmt.Errorf("Failed to attach beeper: %w", err)
+			}
+		}
+
+		if *random {
+			seed := uint32(*randomSeed)
+			if seed == 0 {
+				seed = uint32(time.Now().UnixNano())
+			}
+			if err := cpuInstance.MapPorts(randomPort, devices.RandomSize, devices.NewRandom(seed)); err != nil {
+				return fmt.Errorf("Failed to attach random number generator: %w", err)
+			}
+		}
+
+		if *rtc {
+			rtcDevice := devices.NewRTC()
+			if *rtcStart != "" {
+				start, err := time.Parse(time.RFC3339, *rtcStart)
+				if err != nil {
+					return fmt.Errorf("Invalid clock start time: %w", err)
+				}
+				rtcDevice = devices.NewFixedRTC(start, devices.DefaultClockHz)
+				cpuInstance.AddTicker(rtcDevice)
+			}
+			if err := cpuInstance.MapPorts(rtcPort, devices.RTCSize, rtcDevice); err != nil {
+				return fmt.Errorf("Failed to attach clock: %w", err)
+			}
+		}
+
+		if *dma {
+			dmaDevice := devices.NewDMA(bus)
+			dmaDevice.SetPorts(cpuInstance.Ports())
+			dmaDevice.SetInterrupt(cpuInstance, dmaIRQ)
+			cpuInstance.AddTicker(dmaDevice)
+			if err := cpuInstance.MapPorts(dmaPort, devices.DMASize, dmaDevice); err != nil {
+				return fmt.Errorf("Failed to attach DMA controller: %w", err)
+			}
+		}
+
+		// The CPU sees virtual addresses through the MMU, while devices such as
+		// DMA keep working with physical ones
+		var cpuBus cpu.Bus = bus
+		if *mmu {
+			mmuDevice := cpu.NewMMU(bus)
+			if err := cpuInstance.MapPorts(cpu.MMUPort, cpu.MMURegistersSize, mmuDevice.Registers()); err != nil {
+				return fmt.Errorf("Failed to attach MMU: %w", err)
+			}
+			cpuBus = mmuDevice
+		}
+
+		limits := cpu.Limits{
+			MaxInstructions: *maxSteps,
+			MaxDuration:     *timeout,
+			MaxOutputBytes:  *maxOutput,
+			MaxStackDepth:   *maxStack,
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		err = cpuInstance.ExecuteContext(ctx, cpuBus, limits)
+
+		// Show the final state of the display, even if the program failed
+		if displayDevice != nil {
+			displayDevice.Render(os.Stdout)
+		}
+
 		if err != nil {
-			log.Fatalf("Failed to read file: %v", err)
+			return fmt.Errorf("Failed to run program: %w", err)
 		}
 
-		cpuInstance := cpu.NewCPU()
-		memory := cpu.NewMemory()
-		memory.LoadCode(bytecode)
+		// Keep the final frame of the pixel display
+		if pixelDevice != nil {
+			if err := pixelDevice.Snapshot(); err != nil {
+				return fmt.Errorf("Failed to snapshot pixel display: %w", err)
+			}
+		}
 
-		cpuInstance.Execute(memory)
+		if beeperDevice != nil {
+			beeperDevice.Finish()
+			if err := beeperDevice.SaveWAV(*beeperOutput); err != nil {
+				return fmt.Errorf("Failed to write audio: %w", err)
+			}
+		}
 
-		return
+		return nil
 	}
+
+	return nil
 }